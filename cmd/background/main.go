@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"runtime"
 	"sync"
@@ -79,8 +80,11 @@ func createQuadVAO() uint32 {
 func main() {
 	nc := client.Default()
 	defer nc.Close()
-	cfg := client.GetConfig[config](nc.Request)
-	err := glfw.Init()
+	cfg, err := client.GetConfig[config](context.Background(), nc.Request)
+	if err != nil {
+		panic(err)
+	}
+	err = glfw.Init()
 	if err != nil {
 		panic(err)
 	}
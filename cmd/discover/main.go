@@ -0,0 +1,67 @@
+// List the running services and the subjects they handle.
+//
+//	discover [subject...]
+//
+// If subjects are given, report which of them are not handled by any service instead.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+)
+
+func describe(s *discovery.Schema) string {
+	if s == nil {
+		return ""
+	}
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Items != nil {
+		return s.Type + " of " + describe(s.Items)
+	}
+	return s.Type
+}
+
+func main() {
+	nc := client.Default()
+	defer nc.Close()
+
+	services, err := nc.Request.Discover(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		nc.Close()
+		os.Exit(1)
+	}
+
+	if subjects := os.Args[1:]; len(subjects) > 0 {
+		missing := 0
+		for _, subject := range subjects {
+			if !discovery.Handled(services, subject) {
+				fmt.Printf("%s is not handled\n", subject)
+				missing += 1
+			}
+		}
+		// Deferred calls do not run on exit
+		nc.Close()
+		os.Exit(missing)
+	}
+
+	for _, service := range services {
+		if len(service.Subjects) == 0 {
+			continue
+		}
+		fmt.Printf("%s %s\n", service.Name, service.Version)
+		for _, s := range service.Subjects {
+			if s.Kind == discovery.Request {
+				fmt.Printf("  %s (%s) -> %s\n", s.Subject, describe(s.Request), describe(s.Response))
+			} else {
+				fmt.Printf("  %s (%s)\n", s.Subject, describe(s.Request))
+			}
+		}
+	}
+}
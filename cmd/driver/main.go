@@ -3,13 +3,16 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
 	screenApi "github.com/operdies/windows-nats-shell/pkg/nats/api/screen"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/windows"
 	"github.com/operdies/windows-nats-shell/pkg/nats/client"
 	"github.com/operdies/windows-nats-shell/pkg/utils"
 	"github.com/operdies/windows-nats-shell/pkg/utils/filewatcher"
@@ -34,17 +37,28 @@ type config struct {
 
 func ListenIndefinitely() {
 	nc := client.Default()
-	defer nc.Close()
+	nc.Subscribe.Use(client.Recover())
 
-	cfg := client.GetConfig[config](nc.Request)
+	cfg, err := client.GetConfig[config](context.Background(), nc.Request)
+	if err != nil {
+		panic(err)
+	}
 	filewatcher.SetExtentions(cfg.Launcher.Extensions)
 	indexItems(cfg)
 
+	// The published windows belong to the trace of the shell event which started the batch
+	var batchCtx context.Context
+	var batchLock sync.Mutex
 	// Ensure windows are computed and published at most once per second
-	batchedPublish := utils.Batcher(func() { nc.Publish.WindowsUpdated(wia.GetVisibleWindows()) },
-		time.Millisecond*100, time.Millisecond*500)
+	batchedPublish := utils.Batcher(func() {
+		batchLock.Lock()
+		ctx := batchCtx
+		batchCtx = nil
+		batchLock.Unlock()
+		nc.Publish.WithContext(ctx).WindowsUpdated(wia.GetVisibleWindows())
+	}, time.Millisecond*100, time.Millisecond*500)
 
-	nc.Subscribe.WH_SHELL(func(e shell.ShellEventInfo) {
+	client.ListenContext(*nc.Subscribe, shell.ShellEvents, func(ctx context.Context, e shell.ShellEventInfo) {
 		watched := map[shell.WM_SHELL_CODE]bool{
 			shell.HSHELL_WINDOWACTIVATED:     true,
 			shell.HSHELL_WINDOWCREATED:       true,
@@ -55,6 +69,11 @@ func ListenIndefinitely() {
 		if v, ok := watched[e.ShellCode]; v && ok {
 			// When CREATEWND and DESTROYWND is received, the window operation
 			// has been posted, but not completed. Wait a bit before publishing
+			batchLock.Lock()
+			if batchCtx == nil {
+				batchCtx = ctx
+			}
+			batchLock.Unlock()
 			batchedPublish()
 		}
 	})
@@ -75,27 +94,22 @@ func ListenIndefinitely() {
 		return getFriendlyNames()
 	})
 
-	launch := func(requested string, adm bool) string {
+	launch := func(requested string, adm bool) error {
 		if requested == "" {
-			return "No program specified"
+			return reply.Errorf(reply.InvalidRequest, "No program specified")
 		}
 		val, err := getPathExecutable(requested)
 
 		if err != nil {
-			return err.Error()
+			return err
 		}
 
-		err = winapi.StartDetachedProcess(val, adm)
-
-		if err != nil {
-			return err.Error()
-		}
-		return "Started " + requested
+		return winapi.StartDetachedProcess(val, adm)
 	}
-	nc.Subscribe.LaunchProgram(func(prog string) string {
+	nc.Subscribe.LaunchProgram(func(prog string) error {
 		return launch(prog, false)
 	})
-	nc.Subscribe.LaunchProgramAsAdmin(func(prog string) string {
+	nc.Subscribe.LaunchProgramAsAdmin(func(prog string) error {
 		return launch(prog, true)
 	})
 	nc.Subscribe.GetResolution(func() screenApi.Resolution {
@@ -126,7 +140,45 @@ func ListenIndefinitely() {
 		wia.RestoreOrMinimize(h)
 		return true
 	})
-	select {}
+	nc.Subscribe.MaximizeWindow(func(h wintypes.HWND) bool {
+		if h == 0 {
+			h = winapi.GetForegroundWindow()
+		}
+		return winapi.ShowWindow(h, wintypes.SW_MAZIMIZED)
+	})
+	nc.Subscribe.MoveWindow(func(me windows.MoveEvent) bool {
+		h := me.Handle
+		if h == 0 {
+			h = winapi.GetForegroundWindow()
+		}
+		wia.MoveWindow(h, me.To)
+		return true
+	})
+	nc.Subscribe.ResizeWindow(func(re windows.ResizeEvent) bool {
+		h := re.Handle
+		if h == 0 {
+			h = winapi.GetForegroundWindow()
+		}
+		wia.ResizeWindow(h, int(re.Width), int(re.Height))
+		return true
+	})
+	nc.Subscribe.FocusNext(func(h wintypes.HWND) bool {
+		if h == 0 {
+			h = winapi.GetForegroundWindow()
+		}
+		_, next := wia.GetSiblings(h)
+		return winapi.SuperFocusStealer(next)
+	})
+	nc.Subscribe.FocusPrevious(func(h wintypes.HWND) bool {
+		if h == 0 {
+			h = winapi.GetForegroundWindow()
+		}
+		prev, _ := wia.GetSiblings(h)
+		return winapi.SuperFocusStealer(prev)
+	})
+	// Restore the borders hidden by HideBorder and ToggleBorder
+	nc.OnShutdown(wia.RestoreBorders)
+	nc.Run(context.Background())
 }
 
 func mergeMaps(maps ...map[string]string) map[string]string {
@@ -177,7 +229,7 @@ func getPathExecutable(s string) (prog string, err error) {
 			return
 		}
 	}
-	err = fmt.Errorf("File %s not found.", s)
+	err = reply.Errorf(reply.NotFound, "File %s not found.", s)
 	return
 }
 
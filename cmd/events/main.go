@@ -2,19 +2,19 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/natefinch/npipe"
-	"github.com/nats-io/nats.go"
 	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
 	"github.com/operdies/windows-nats-shell/pkg/input/mouse"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
 	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+	"github.com/operdies/windows-nats-shell/pkg/nats/codec"
 	"github.com/operdies/windows-nats-shell/pkg/utils/query"
 	"github.com/operdies/windows-nats-shell/pkg/winapi"
 	"github.com/operdies/windows-nats-shell/pkg/wintypes"
@@ -34,7 +34,7 @@ type config struct {
 var nc client.Client
 
 func init() {
-	nc, _ = client.New(nats.DefaultURL, time.Second)
+	nc = client.Default()
 }
 
 func publishEvent(eventType string, arguments []string) {
@@ -86,7 +86,10 @@ func server() {
 }
 
 func main() {
-	cfg := client.GetConfig[config](nc.Request)
+	cfg, err := client.GetConfig[config](context.Background(), nc.Request)
+	if err != nil {
+		panic(err)
+	}
 
 	if cfg.ShellEvents {
 		hook := winapi.SetWindowsHookExW(wintypes.WH_SHELL, shellProc.Addr(), wintypes.HINSTANCE(hookDll.Handle), 0)
@@ -94,9 +97,12 @@ func main() {
 		go server()
 	}
 
+	// Input events are published at a high rate, so use a compact encoding
+	inputPublisher := nc.Publish.WithCodec(codec.MsgPack)
+
 	if cfg.KeyboardEvents {
 		hook, _ := keyboard.InstallHook(func(kei keyboard.KeyboardEventInfo) bool {
-			nc.Publish.WH_KEYBOARD(kei)
+			inputPublisher.WH_KEYBOARD(kei)
 			return false
 		})
 		defer hook.Uninstall()
@@ -104,11 +110,11 @@ func main() {
 
 	if cfg.MouseEvents {
 		hook, _ := mouse.InstallHook(func(mei mouse.MouseEventInfo) bool {
-			nc.Publish.WH_MOUSE(mei)
+			inputPublisher.WH_MOUSE(mei)
 			return false
 		})
 		defer hook.Uninstall()
 	}
 
-	select {}
+	nc.Run(context.Background())
 }
@@ -1,12 +1,12 @@
 package keymap
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 	"syscall"
 
-	"github.com/nats-io/nats.go"
 	"github.com/operdies/windows-nats-shell/pkg/input"
 	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
 	"github.com/operdies/windows-nats-shell/pkg/nats/client"
@@ -112,22 +112,13 @@ func ParseMod(parts []string) hotkey {
 }
 
 var (
-	nc *nats.Conn
+	nc client.Client
 )
 
-func init() {
-	var err error
-	nc, err = nats.Connect(nats.DefaultURL)
-	if err != nil {
-		panic(err)
-	}
-}
-
 func unleash(m *BindingTree) {
 	for i, act := range m.Action {
-		msg := utils.EncodeAny(act.Nats.Payload)
-		nc.Publish(act.Nats.Subject, msg)
-		fmt.Printf("%d) Unleash: %+v\nWith payload:\n%v\n", i, act.Nats, string(msg))
+		nc.Publish.Message(act.Nats.Subject, act.Nats.Payload)
+		fmt.Printf("%d) Unleash: %+v\nWith payload:\n%v\n", i, act.Nats, string(utils.EncodeAny(act.Nats.Payload)))
 	}
 }
 
@@ -219,12 +210,14 @@ func buildTree(keys []hotkey) *BindingTree {
 	return result
 }
 
-func Create() *Keymap {
+func Create(c client.Client) *Keymap {
+	nc = c
 	var result Keymap
 	result.activeMods = map[input.VKEY]bool{}
-	c := client.Default()
-	cfg := client.GetConfig[config](c.Request)
-	c.Close()
+	cfg, err := client.GetConfig[config](context.Background(), nc.Request)
+	if err != nil {
+		panic(err)
+	}
 
 	hotkeys := make([]hotkey, 0, len(cfg.Keymap))
 
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/operdies/windows-nats-shell/cmd/hotkeys/keymap"
 	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
 )
 
 func dumpTree(mods []uint32, bt *keymap.BindingTree) {
@@ -19,13 +21,14 @@ func dumpTree(mods []uint32, bt *keymap.BindingTree) {
 }
 
 func main() {
-	km := keymap.Create()
+	nc := client.Default()
+	km := keymap.Create(nc)
 	dumpTree(nil, km.Bindings)
 
 	// Maybe this needs to be a WindowsHookEvent callback in the future.
 	// For simplicity, let's stick to subscribing for now.
 	// A windows hook event would allow us to avoid propagating handled events
 	hook, _ := keyboard.InstallHook(km.ProcessEvent)
-	defer hook.Uninstall()
-	select {}
+	nc.OnShutdown(func() { hook.Uninstall() })
+	nc.Run(context.Background())
 }
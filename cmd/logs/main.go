@@ -0,0 +1,77 @@
+// The log service records the output of every service, and answers Logs.Query requests.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/cmd/logs/store"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+)
+
+type config struct {
+	// Defaults to windows-nats-shell/logs in the user cache directory (os.UserCacheDir)
+	Directory string
+	// In megabytes
+	MaxFileSize int
+	MaxFiles    int
+	// In hours
+	MaxAge int
+}
+
+func main() {
+	nc := client.Default()
+	cfg, err := client.GetConfig[config](context.Background(), nc.Request)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Directory == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			panic(err)
+		}
+		cfg.Directory = filepath.Join(cache, "windows-nats-shell", "logs")
+	}
+	s, err := store.Open(store.Options{
+		Directory:   cfg.Directory,
+		MaxFileSize: int64(cfg.MaxFileSize) * 1024 * 1024,
+		MaxFiles:    cfg.MaxFiles,
+		MaxAge:      time.Hour * time.Duration(cfg.MaxAge),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	// Only report the first of consecutive failures. The report is itself recorded.
+	// Stdout and stderr are recorded concurrently, so failing is guarded by lock.
+	var lock sync.Mutex
+	failing := false
+	record := func(e logs.Entry) {
+		err := s.Add(e)
+		lock.Lock()
+		report := err != nil && !failing
+		failing = err != nil
+		lock.Unlock()
+		if report {
+			log.Printf("Failed to record logs. %v\n", err)
+		}
+	}
+	nc.Subscribe.Output(logs.Stdout, "", record)
+	nc.Subscribe.Output(logs.Stderr, "", record)
+	nc.Subscribe.QueryLogs(func(f logs.Filter) ([]logs.Entry, error) {
+		entries, err := s.Query(f)
+		if err != nil {
+			return nil, reply.Errorf(reply.InvalidRequest, "%v", err)
+		}
+		return entries, nil
+	})
+
+	nc.Run(context.Background())
+}
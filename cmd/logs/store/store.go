@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+)
+
+// Options of a store. Zero values are replaced by defaults.
+type Options struct {
+	// The directory the log files are stored in
+	Directory string
+	// Start a new file when the current file grows larger than this many bytes. Defaults to 10 MB.
+	MaxFileSize int64
+	// Delete the oldest files when there are more files than this. Defaults to 10.
+	MaxFiles int
+	// Delete files which were not written to for this long. Zero keeps files until there are too many.
+	MaxAge time.Duration
+}
+
+// Store records entries in rotating files with one JSON entry per line.
+// Files are named after the time they were created, so they sort from oldest to newest.
+type Store struct {
+	lock    sync.Mutex
+	opts    Options
+	files   []string
+	current *os.File
+	size    int64
+	closed  bool
+}
+
+const (
+	extension  = ".jsonl"
+	timeFormat = "20060102T150405.000000000"
+)
+
+// Open the store in a directory, and continue writing to the newest file in it
+func Open(opts Options) (*Store, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = 10 * 1024 * 1024
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = 10
+	}
+	if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(opts.Directory, "*"+extension))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	s := &Store{opts: opts, files: files}
+	s.prune()
+	if len(s.files) > 0 {
+		name := s.files[len(s.files)-1]
+		if s.current, err = os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+			return nil, err
+		}
+		info, err := s.current.Stat()
+		if err != nil {
+			s.current.Close()
+			return nil, err
+		}
+		s.size = info.Size()
+	}
+	return s, nil
+}
+
+// Record an entry
+func (s *Store) Add(e logs.Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if s.current == nil || s.size+int64(len(line)) > s.opts.MaxFileSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.current.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Start a new file, and delete old files. Must be called with lock held.
+func (s *Store) rotate() error {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+	name := filepath.Join(s.opts.Directory, time.Now().UTC().Format(timeFormat)+extension)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.current = f
+	s.size = 0
+	if len(s.files) == 0 || s.files[len(s.files)-1] != name {
+		s.files = append(s.files, name)
+	}
+	s.prune()
+	return nil
+}
+
+// Delete the oldest files until the retention limits are met. The newest file is never deleted.
+func (s *Store) prune() {
+	for len(s.files) > 1 {
+		oldest := s.files[0]
+		if len(s.files) <= s.opts.MaxFiles {
+			info, err := os.Stat(oldest)
+			if err == nil && (s.opts.MaxAge <= 0 || time.Since(info.ModTime()) < s.opts.MaxAge) {
+				return
+			}
+		}
+		os.Remove(oldest)
+		s.files = s.files[1:]
+	}
+}
+
+// Get the most recent entries which match a filter, oldest first
+func (s *Store) Query(f logs.Filter) ([]logs.Entry, error) {
+	match, err := f.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = logs.DefaultLimit
+	}
+
+	s.lock.Lock()
+	files := append([]string(nil), s.files...)
+	s.lock.Unlock()
+
+	var result []logs.Entry
+	for i, name := range files {
+		// A file only contains entries written before the next file was created
+		if i+1 < len(files) && !f.Since.IsZero() && created(files[i+1]).Before(f.Since) {
+			continue
+		}
+		result = scan(name, match, limit, result)
+	}
+	if len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result, nil
+}
+
+// Append the matching entries of a file to result. Only the last limit entries are kept.
+func scan(name string, match func(logs.Entry) bool, limit int, result []logs.Entry) []logs.Entry {
+	f, err := os.Open(name)
+	if err != nil {
+		// The file was deleted after the query started
+		return result
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e logs.Entry
+		// The last line may be incomplete if it is being written
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || !match(e) {
+			continue
+		}
+		result = append(result, e)
+		if len(result) >= 2*limit {
+			result = append(result[:0], result[len(result)-limit:]...)
+		}
+	}
+	return result
+}
+
+// Get the time a file was created from its name
+func created(name string) time.Time {
+	t, _ := time.Parse(timeFormat, strings.TrimSuffix(filepath.Base(name), extension))
+	return t
+}
+
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	if s.current == nil {
+		return nil
+	}
+	err := s.current.Close()
+	s.current = nil
+	return err
+}
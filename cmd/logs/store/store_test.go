@@ -0,0 +1,123 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+)
+
+func entry(service string, i int) logs.Entry {
+	return logs.Entry{Service: service, Stream: logs.Stdout, Time: time.Now(), Sequence: i, Line: fmt.Sprintf("line %d", i)}
+}
+
+func TestQuery(t *testing.T) {
+	s, err := Open(Options{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 1; i <= 10; i++ {
+		s.Add(entry("driver", i))
+		s.Add(entry("hotkeys", i))
+	}
+	errLine := entry("driver", 11)
+	errLine.Stream = logs.Stderr
+	s.Add(errLine)
+
+	cases := []struct {
+		filter   logs.Filter
+		expected int
+		last     string
+	}{
+		{logs.Filter{}, 21, "line 11"},
+		{logs.Filter{Service: "driver"}, 11, "line 11"},
+		{logs.Filter{Service: "driver", Stream: logs.Stdout}, 10, "line 10"},
+		{logs.Filter{Stream: logs.Stderr}, 1, "line 11"},
+		{logs.Filter{Service: "hotkeys", Limit: 3}, 3, "line 10"},
+		{logs.Filter{Contains: "line 1"}, 5, "line 11"},
+		{logs.Filter{Regex: `^line [0-2]$`, Service: "hotkeys"}, 2, "line 2"},
+		{logs.Filter{Since: time.Now()}, 0, ""},
+	}
+	for i, c := range cases {
+		result, err := s.Query(c.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != c.expected || (c.expected > 0 && result[len(result)-1].Line != c.last) {
+			t.Fatalf("Case %d: expected %d entries ending with '%s', got %+v", i, c.expected, c.last, result)
+		}
+	}
+	if _, err := s.Query(logs.Filter{Regex: "("}); err == nil {
+		t.Fatalf("Expected an invalid regex to fail")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Directory: dir, MaxFileSize: 512, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 100; i++ {
+		if err := s.Add(entry("driver", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	if err := s.Add(entry("driver", 101)); err == nil {
+		t.Fatalf("Expected adding to a closed store to fail")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+extension))
+	if len(files) > 3 {
+		t.Fatalf("Expected at most 3 files, got %d", len(files))
+	}
+	for _, name := range files {
+		if info, _ := os.Stat(name); info.Size() > 512 {
+			t.Fatalf("Expected files to be rotated, %s is %d bytes", name, info.Size())
+		}
+	}
+
+	// The store continues where it left off
+	s, err = Open(Options{Directory: dir, MaxFileSize: 512, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Add(entry("driver", 101))
+	result, _ := s.Query(logs.Filter{Limit: 1000})
+	if len(result) == 0 || len(result) >= 100 || result[len(result)-1].Sequence != 101 {
+		t.Fatalf("Expected the oldest entries to be deleted, got %d entries", len(result))
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i].Sequence != result[i-1].Sequence+1 {
+			t.Fatalf("Expected consecutive entries, got %d after %d", result[i].Sequence, result[i-1].Sequence)
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, time.Now().Add(-time.Hour).UTC().Format(timeFormat)+extension)
+	os.WriteFile(old, nil, 0644)
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+
+	s, err := Open(Options{Directory: dir, MaxAge: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// The newest file is kept until a new file is created
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("Expected the newest file to be kept")
+	}
+	s.opts.MaxFileSize = 1
+	s.Add(entry("driver", 1))
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("Expected the expired file to be deleted")
+	}
+}
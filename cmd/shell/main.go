@@ -1,132 +1,181 @@
-//go:build windows && amd64
-// +build windows,amd64
-
 package main
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"time"
 
-	"github.com/nats-io/nats.go"
 	"github.com/operdies/windows-nats-shell/cmd/shell/registration"
 	"github.com/operdies/windows-nats-shell/cmd/shell/service"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
 	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
+	"github.com/operdies/windows-nats-shell/pkg/utils/filewatcher"
 )
 
 func start(config *shell.Configuration) bool {
-	var subs []*nats.Subscription
-	var jobs map[string]*service.ProcessJob
-	quit := make(chan bool)
+	// Buffered, so handlers never block if the shell is already quitting
+	quit := make(chan bool, 1)
+	requestQuit := func(restart bool) {
+		select {
+		case quit <- restart:
+		default:
+		}
+	}
 	log.Println("Starting shell!")
 
-	client, err := client.New(nats.DefaultURL, time.Second)
+	natsConfig := config.Nats
+	natsConfig.Name = "shell"
+	nc, err := client.Connect(natsConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	defer func() {
-		for _, s := range subs {
-			s.Unsubscribe()
-		}
-		client.Close()
-	}()
+	defer nc.Close()
+	nc.Subscribe.Use(client.Recover())
 
-	s, _ := client.Subscribe.StartService(func(s string) error {
-		job, ok := jobs[s]
-		if ok {
-			return job.Start()
-		}
-		return fmt.Errorf("Service '%s' is not configured.", s)
+	supervisor := service.NewSupervisor(config)
+	supervisor.Prepare = func(job *service.ProcessJob) {
+		job.Toast = func(t shell.Toast) { nc.Publish.ShellToast(t) }
+		job.Requester = nc.Request
+		job.Completed = func(run shell.ServiceRun) { nc.Publish.ServiceCompleted(run) }
+		job.Events = func(event shell.ServiceEvent) { nc.Publish.ServiceEvent(event) }
+	}
+	nc.Subscribe.ServiceReady(supervisor.Ready)
+
+	nc.Subscribe.StartService(supervisor.Start)
+	nc.Subscribe.RunNow(supervisor.RunNow)
+	nc.Subscribe.StopService(supervisor.Stop)
+	nc.Subscribe.RestartService(supervisor.Restart)
+	nc.Subscribe.RestartShell(func() error {
+		log.Println("Restart Shell!")
+		requestQuit(true)
+		return nil
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.StopService(func(s string) error {
-		job, ok := jobs[s]
-		if ok {
-			return job.Stop()
+
+	// Apply a new config. Changes to the NATS config require a restart of the shell.
+	update := func(update func(current *shell.Configuration) (*shell.Configuration, error)) (shell.ConfigDiff, error) {
+		diff, err := supervisor.Update(update)
+		if err == nil && diff.Nats {
+			requestQuit(true)
 		}
-		return fmt.Errorf("Service '%s' is not configured.", s)
+		return diff, err
+	}
+	invalid := func(err error) error {
+		if err == nil {
+			return nil
+		}
+		return reply.Errorf(reply.InvalidRequest, "%v", err)
+	}
+
+	// Apply changes to the config file
+	reload := func() (shell.ConfigDiff, error) {
+		return update(func(current *shell.Configuration) (*shell.Configuration, error) {
+			next, err := current.Reload()
+			if err != nil {
+				return nil, reply.Errorf(reply.InvalidRequest, "Error in config: %v", err)
+			}
+			return next, nil
+		})
+	}
+	nc.Subscribe.ReloadConfig(reload)
+	// Changes are written to the config file before they are applied,
+	// so the running config never gets ahead of a config file which failed to save.
+	nc.Subscribe.SetConfig(func(req shell.SetConfigRequest) (shell.ConfigDiff, error) {
+		return update(func(current *shell.Configuration) (*shell.Configuration, error) {
+			next, err := shell.ParseFile(current.Path, []byte(req.Config))
+			if err != nil {
+				return nil, reply.Errorf(reply.InvalidRequest, "Error in config: %v", err)
+			}
+			if current.Diff(next).Nats && !req.Persist {
+				return nil, reply.Errorf(reply.InvalidRequest, "The NATS config can only be changed if the config is persisted.")
+			}
+			if req.Persist {
+				if err := os.WriteFile(current.Path, []byte(req.Config), 0644); err != nil {
+					return nil, err
+				}
+			}
+			return next, nil
+		})
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.RestartService(func(s string) error {
-		job, ok := jobs[s]
-		if ok {
-			cfg2, err := config.Reload()
+	nc.Subscribe.AddService(func(req shell.AddServiceRequest) error {
+		_, err := update(func(current *shell.Configuration) (*shell.Configuration, error) {
+			if _, ok := current.Services[req.Name]; ok {
+				return nil, reply.Errorf(reply.InvalidRequest, "Service '%s' is already configured.", req.Name)
+			}
+			next, err := current.WithService(req.Name, req.Definition)
 			if err != nil {
-				log.Printf("Error in config: %v", err)
-			} else {
-				if newCfg, ok := cfg2.Services[s]; ok {
-					config.Services[s] = newCfg
-					config.ServiceConfigs[s] = cfg2.ServiceConfigs[s]
+				return nil, invalid(err)
+			}
+			if req.Persist {
+				if err := shell.SaveService(current.Path, req.Name, req.Definition); err != nil {
+					return nil, err
 				}
 			}
-			job.Stop()
-			job = service.NewProcessJob(s, config.Services[s])
-			job.StartCount = jobs[s].StartCount
-			jobs[s] = job
-			go job.Start()
-			return nil
-		}
-		return fmt.Errorf("Service '%s' is not configured.", s)
+			return next, nil
+		})
+		return err
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.RestartShell(func() error {
-		log.Println("Restart Shell!")
-		quit <- true
-		return nil
+	nc.Subscribe.RemoveService(func(req shell.RemoveServiceRequest) error {
+		_, err := update(func(current *shell.Configuration) (*shell.Configuration, error) {
+			if _, ok := current.Services[req.Name]; !ok {
+				return nil, reply.Errorf(reply.NotConfigured, "Service '%s' is not configured.", req.Name)
+			}
+			next, err := current.WithoutService(req.Name)
+			if err != nil {
+				return nil, invalid(err)
+			}
+			if req.Persist {
+				if err := shell.DeleteService(current.Path, req.Name); err != nil {
+					return nil, err
+				}
+			}
+			return next, nil
+		})
+		return err
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.QuitShell(func() error {
-		quit <- false
+	if config.WatchConfig {
+		watcher, err := filewatcher.WatchFile(config.Path, func() {
+			if _, err := reload(); err != nil {
+				log.Println(err)
+			}
+		})
+		if err != nil {
+			log.Printf("Not watching %s. %v\n", config.Path, err)
+		} else {
+			defer watcher.Close()
+		}
+	}
+	nc.Subscribe.QuitShell(func() error {
+		requestQuit(false)
 		return nil
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.Config(func(key string) any {
-		if section, ok := config.ServiceConfigs[key]; ok {
-			return &section
+	nc.Subscribe.Config(func(key string) (any, error) {
+		if section, ok := supervisor.Config().ServiceConfigs[key]; ok {
+			return &section, nil
 		}
-		return nil
+		return nil, reply.Errorf(reply.NotConfigured, "Service '%s' is not configured.", key)
 	})
-	subs = append(subs, s)
-	s, _ = client.Subscribe.ShellConfig(func() shell.Configuration {
-		return *config
+	nc.Subscribe.ShellConfig(func() shell.Configuration {
+		// Clients only need to know where the server is
+		redacted := *supervisor.Config()
+		redacted.Nats = redacted.Nats.Redacted()
+		return redacted
 	})
-
-	stopJobs := func() {
-		if jobs != nil {
-			for _, job := range jobs {
-				job.Stop()
-			}
-		}
-	}
-	defer stopJobs()
-
-	reloadConfig := func() error {
-		stopJobs()
-
-		jobs = map[string]*service.ProcessJob{}
-
-		for name, ser := range config.Services {
-			jobs[name] = service.NewProcessJob(name, ser)
-		}
-
-		for _, job := range jobs {
-			go job.Start()
-		}
-		return nil
-	}
-
-	err = reloadConfig()
-	if err != nil {
+	nc.Subscribe.ListServices(supervisor.List)
+	nc.Subscribe.ServiceStatus(supervisor.Status)
+	spans := trace.NewStore(10000)
+	nc.Subscribe.TraceSpans(spans.Add)
+	nc.Subscribe.Trace(spans.Get)
+	nc.Subscribe.Discover(nc.Request.Describe)
+
+	defer supervisor.StopAll()
+	if err := supervisor.StartAll(); err != nil {
 		panic(err.Error())
 	}
 
-	restart := <-quit
-	close(quit)
-	return restart
+	return <-quit
 }
 
 func main() {
@@ -136,7 +185,7 @@ func main() {
 	os.Chdir(here)
 	config := shell.LoadDefault()
 
-	shellLogger := service.CreateNatsStdout("shell")
+	shellLogger := service.CreateNatsStdout("shell", config.Nats)
 	log.SetOutput(shellLogger)
 
 	for start(config) {
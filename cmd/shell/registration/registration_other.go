@@ -0,0 +1,7 @@
+//go:build !(windows && amd64)
+// +build !windows !amd64
+
+package registration
+
+// Other platforms have no shell to replace, so the shell runs headless
+func RegisterThisProcessAsShell() {}
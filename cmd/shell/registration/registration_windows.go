@@ -1,3 +1,6 @@
+//go:build windows && amd64
+// +build windows,amd64
+
 package registration
 
 import (
@@ -0,0 +1,30 @@
+package service
+
+import "os"
+
+// processGroup contains the process of a service, and every process it starts,
+// so processes started by a service do not outlive it.
+type processGroup interface {
+	// Add the process of the service after it started, and resume it if the group started it suspended
+	add(p *os.Process) error
+	// Kill every process in the group
+	kill() error
+	// Kill the processes which are left after the process of the service exited, and release the group
+	release()
+}
+
+// A group which only contains the process of the service. Processes it starts are left running.
+type singleProcess struct {
+	process *os.Process
+}
+
+func (g *singleProcess) add(p *os.Process) error {
+	g.process = p
+	return nil
+}
+
+func (g *singleProcess) kill() error {
+	return g.process.Kill()
+}
+
+func (g *singleProcess) release() {}
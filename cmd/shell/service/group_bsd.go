@@ -0,0 +1,9 @@
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package service
+
+import "syscall"
+
+// There is no parent death signal, so services outlive a shell which exits without stopping them
+func killWithShell(attr *syscall.SysProcAttr) {}
@@ -0,0 +1,12 @@
+//go:build linux
+// +build linux
+
+package service
+
+import "syscall"
+
+// Kill the process of the service if the shell exits without stopping it, like the job object does on Windows.
+// The signal is sent when the thread which started the process exits, and only reaches the process of the service.
+func killWithShell(attr *syscall.SysProcAttr) {
+	attr.Pdeathsig = syscall.SIGKILL
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestProcessGroupDiesWithShell(t *testing.T) {
+	cmd := exec.Command("true")
+	newProcessGroup(cmd)
+	if cmd.SysProcAttr.Pdeathsig != syscall.SIGKILL {
+		t.Fatalf("Expected supervised services to be killed with the shell")
+	}
+
+	detached := exec.Command("true")
+	ownGroup(detached)
+	if detached.SysProcAttr.Pdeathsig != 0 {
+		t.Fatalf("Expected detached services to outlive the shell")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !(windows && amd64) && !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !windows !amd64
+// +build !linux
+// +build !darwin
+// +build !freebsd
+// +build !netbsd
+// +build !openbsd
+
+package service
+
+import "os/exec"
+
+func newProcessGroup(cmd *exec.Cmd) processGroup {
+	return &singleProcess{}
+}
+
+func ownGroup(cmd *exec.Cmd) {}
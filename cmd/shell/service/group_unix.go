@@ -0,0 +1,58 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// A process group. The process of the service leads the group, and the processes it starts join it.
+// Processes which start their own group or session leave it.
+type unixGroup struct {
+	lock     sync.Mutex
+	pgid     int
+	released bool
+}
+
+func newProcessGroup(cmd *exec.Cmd) processGroup {
+	ownGroup(cmd)
+	killWithShell(cmd.SysProcAttr)
+	return &unixGroup{}
+}
+
+// Start the process in a process group of its own
+func ownGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func (g *unixGroup) add(p *os.Process) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.pgid = p.Pid
+	return nil
+}
+
+func (g *unixGroup) kill() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.released {
+		return nil
+	}
+	return syscall.Kill(-g.pgid, syscall.SIGKILL)
+}
+
+func (g *unixGroup) release() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.released {
+		g.released = true
+		syscall.Kill(-g.pgid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// Start a helper which starts a child, and get the pid of the child
+func startTree(t *testing.T, behavior string, keepChildren bool) (*ProcessJob, int) {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	s := helper(behavior)
+	s.Environment = append(s.Environment, pidKey+"="+pidFile)
+	s.KeepChildren = keepChildren
+	j := NewProcessJob(behavior, s, shell.NatsConfig{})
+	if err := j.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, _ := os.ReadFile(pidFile)
+		if pid, err := strconv.Atoi(string(content)); err == nil {
+			return j, pid
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to start a child", behavior)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+// Check if a process is running. Killed children may linger as zombies if nothing reaps them.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	return err != nil || !strings.Contains(string(stat), ") Z ")
+}
+
+func waitForExit(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected process %d to be killed", pid)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestStopKillsProcessTree(t *testing.T) {
+	j, child := startTree(t, "tree", false)
+	if err := j.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	waitForExit(t, child)
+}
+
+func TestExitKillsProcessesLeftBehind(t *testing.T) {
+	j, child := startTree(t, "orphan", false)
+	waitFor(t, j, shell.StateStopped)
+	waitForExit(t, child)
+}
+
+func TestKeepChildren(t *testing.T) {
+	j, child := startTree(t, "orphan", true)
+	defer syscall.Kill(child, syscall.SIGKILL)
+	// The child holds on to the output of the service, which must not keep the service from exiting
+	waitFor(t, j, shell.StateStopped)
+	if !alive(child) {
+		t.Fatalf("Expected the child to keep running")
+	}
+}
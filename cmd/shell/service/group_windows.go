@@ -0,0 +1,113 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// A job object. Processes created by a process in a job are also in the job.
+// The process of the service starts suspended, and is resumed once it is in the job,
+// so it cannot start processes which escape the job.
+type jobObject struct {
+	lock     sync.Mutex
+	handle   windows.Handle
+	process  *os.Process
+	released bool
+}
+
+func newProcessGroup(cmd *exec.Cmd) processGroup {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	return &jobObject{}
+}
+
+func (g *jobObject) add(p *os.Process) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.process = p
+	// The process is resumed even if it cannot be added to the job
+	defer resume(uint32(p.Pid))
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return err
+	}
+	// The processes are also killed if the shell exits without stopping them
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	_, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	g.handle = job
+	return nil
+}
+
+func (g *jobObject) kill() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.released {
+		return nil
+	}
+	if g.handle == 0 {
+		return g.process.Kill()
+	}
+	return windows.TerminateJobObject(g.handle, 1)
+}
+
+func (g *jobObject) release() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.released = true
+	if g.handle == 0 {
+		return
+	}
+	windows.TerminateJobObject(g.handle, 1)
+	windows.CloseHandle(g.handle)
+	g.handle = 0
+}
+
+// Resume the threads of a process which was created suspended
+func resume(pid uint32) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != pid {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+)
+
+// Run the health check of the service until done is closed
+func (j *ProcessJob) watchHealth(done <-chan struct{}) {
+	check := j.service.Health
+	if check.Interval <= 0 || j.Requester == nil {
+		return
+	}
+	threshold := check.Threshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	ticker := time.NewTicker(time.Millisecond * time.Duration(check.Interval))
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		err := client.HealthCheck(context.Background(), *j.Requester, j.name, check)
+		if err == nil {
+			if j.setUnhealthy(false) {
+				log.Printf("%s is healthy again.\n", j.name)
+			}
+			failures = 0
+			continue
+		}
+
+		failures += 1
+		log.Printf("Health check of %s failed (%d/%d). %v\n", j.name, failures, threshold, err)
+		if failures < threshold || !j.setUnhealthy(true) {
+			continue
+		}
+		if j.Toast != nil {
+			j.Toast(shell.Toast{Title: fmt.Sprintf("%s is unhealthy", j.name), Message: err.Error(), Level: shell.Critical, Duration: 10000})
+		}
+		if check.Restart {
+			log.Printf("Restarting unhealthy service %s.\n", j.name)
+			go j.restartUnhealthy()
+			return
+		}
+	}
+}
+
+// Update the health of the service, and report whether it changed
+func (j *ProcessJob) setUnhealthy(unhealthy bool) bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	changed := j.unhealthy != unhealthy
+	j.unhealthy = unhealthy
+	return changed
+}
+
+func (j *ProcessJob) restartUnhealthy() {
+	if err := j.Stop(); err != nil {
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	// Unless something else started or stopped the service in the meantime
+	if j.state == shell.StateStopped {
+		j.spawn()
+	}
+}
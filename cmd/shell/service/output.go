@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+)
+
+// NatsWriter publishes every line written to it as a message. A line is published once it is
+// terminated, so lines are never split or merged, regardless of how they were written.
+type NatsWriter struct {
+	nc      *nats.Conn
+	subject string
+
+	lock     sync.Mutex
+	line     []byte
+	sequence int
+	// Set while the rest of a line longer than logs.MaxLineLength is discarded
+	discarding bool
+}
+
+func NewNatsWriter(nc *nats.Conn, subject string) *NatsWriter {
+	return &NatsWriter{nc: nc, subject: subject}
+}
+
+// Connect to NATS to publish the output of a process.
+// If NATS is unavailable, the connection is nil, and output is discarded.
+func connectOutput(cfg shell.NatsConfig) *nats.Conn {
+	nc, _ := nats.Connect(client.Url(cfg), client.Options(cfg)...)
+	return nc
+}
+
+// Publish lines to the stdout subject of a service
+func CreateNatsStdout(name string, cfg shell.NatsConfig) *NatsWriter {
+	return NewNatsWriter(connectOutput(cfg), logs.Subject(logs.Stdout, name))
+}
+
+func (w *NatsWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n := len(data)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			w.append(data)
+			return n, nil
+		}
+		w.append(data[:i])
+		if w.discarding {
+			w.discarding = false
+		} else {
+			w.publish(false)
+		}
+		data = data[i+1:]
+	}
+}
+
+// Publish the current line, even though it was not terminated
+func (w *NatsWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.line) > 0 && !w.discarding {
+		w.publish(false)
+	}
+	w.discarding = false
+}
+
+func (w *NatsWriter) append(data []byte) {
+	if w.discarding {
+		return
+	}
+	room := logs.MaxLineLength - len(w.line)
+	if len(data) <= room {
+		w.line = append(w.line, data...)
+		return
+	}
+	w.line = append(w.line, data[:room]...)
+	w.publish(true)
+	w.discarding = true
+}
+
+func (w *NatsWriter) publish(truncated bool) {
+	w.sequence += 1
+	msg := nats.NewMsg(w.subject)
+	msg.Data = bytes.TrimSuffix(w.line, []byte("\r"))
+	msg.Header.Set(logs.TimestampHeader, time.Now().Format(time.RFC3339Nano))
+	msg.Header.Set(logs.SequenceHeader, strconv.Itoa(w.sequence))
+	if truncated {
+		msg.Header.Set(logs.TruncatedHeader, "true")
+	}
+	// The message is copied to the outgoing buffer, so the line can be reused
+	w.nc.PublishMsg(msg)
+	w.line = w.line[:0]
+}
+
+// How long to wait for the output of a process after it exited. Processes it left running
+// may hold on to its output, if they are not killed with it.
+const outputTimeout = time.Second
+
+// Pipes which copy the output of a process to writers. If the output of a process is not a file,
+// exec.Cmd.Wait also waits for every process which inherited it, i.e. processes left behind by a service.
+// With pipes, the processes left behind can be killed once the process of the service exited.
+type outputPipes struct {
+	readers []*os.File
+	writers []*os.File
+	copying sync.WaitGroup
+}
+
+func pipeOutput(cmd *exec.Cmd, stdout, stderr io.Writer) (*outputPipes, error) {
+	p := &outputPipes{}
+	for _, w := range []io.Writer{stdout, stderr} {
+		r, pw, err := os.Pipe()
+		if err != nil {
+			p.started()
+			p.wait(0)
+			return nil, err
+		}
+		p.readers = append(p.readers, r)
+		p.writers = append(p.writers, pw)
+		p.copying.Add(1)
+		go func(w io.Writer) {
+			defer p.copying.Done()
+			io.Copy(w, r)
+		}(w)
+	}
+	cmd.Stdout, cmd.Stderr = p.writers[0], p.writers[1]
+	return p, nil
+}
+
+// Close the ends of the pipes which were passed to the process. Must be called after the process started.
+func (p *outputPipes) started() {
+	for _, w := range p.writers {
+		w.Close()
+	}
+}
+
+// Wait for every process which writes to the pipes to exit, and stop copying after timeout
+func (p *outputPipes) wait(timeout time.Duration) {
+	withTimeout(func() struct{} {
+		p.copying.Wait()
+		return struct{}{}
+	}, timeout)
+	for _, r := range p.readers {
+		r.Close()
+	}
+}
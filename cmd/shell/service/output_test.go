@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+)
+
+func TestNatsWriter(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	s := natsserver.RunServer(&opts)
+	defer s.Shutdown()
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	messages := make(chan *nats.Msg, 100)
+	nc.ChanSubscribe(logs.Subject(logs.Stdout, "test"), messages)
+	nc.Flush()
+
+	w := NewNatsWriter(nc, logs.Subject(logs.Stdout, "test"))
+	w.Write([]byte("split "))
+	w.Write([]byte("line\r\nmerged\n"))
+	w.Write([]byte("lines\n\n" + strings.Repeat("x", logs.MaxLineLength+10)))
+	w.Write([]byte("y\nunterminated"))
+	w.Flush()
+
+	expected := []string{"split line", "merged", "lines", "", strings.Repeat("x", logs.MaxLineLength), "unterminated"}
+	for i, line := range expected {
+		var msg *nats.Msg
+		select {
+		case msg = <-messages:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected line %d", i+1)
+		}
+		if string(msg.Data) != line {
+			t.Fatalf("Expected line %d to be '%.20s', got '%.20s'", i+1, line, msg.Data)
+		}
+		if seq := msg.Header.Get(logs.SequenceHeader); seq != strconv.Itoa(i+1) {
+			t.Fatalf("Expected sequence %d, got %s", i+1, seq)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, msg.Header.Get(logs.TimestampHeader)); err != nil {
+			t.Fatal(err)
+		}
+		if truncated := msg.Header.Get(logs.TruncatedHeader) != ""; truncated != (i == 4) {
+			t.Fatalf("Expected only line 5 to be truncated, got line %d", i+1)
+		}
+	}
+	select {
+	case msg := <-messages:
+		t.Fatalf("Expected no more lines, got '%s'", msg.Data)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// Output is discarded without a connection
+	NewNatsWriter(nil, "stdout.test").Write([]byte("lost\n"))
+}
@@ -0,0 +1,28 @@
+//go:build !(windows && amd64)
+// +build !windows !amd64
+
+package service
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// There are no windows to hide
+func configure(cmd *exec.Cmd, s *shell.Service) {}
+
+// Start the service in its own process group, so it is not stopped with the shell
+func startDetached(s *shell.Service) error {
+	cmd := exec.Command(s.Executable, s.Arguments...)
+	cmd.Dir = s.WorkingDirectory
+	cmd.Env = append(os.Environ(), s.Environment...)
+	ownGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the process when it exits
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/winapi"
+	"golang.org/x/sys/windows"
+)
+
+func configure(cmd *exec.Cmd, s *shell.Service) {
+	if s.Visible == false {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		cmd.SysProcAttr.HideWindow = true
+	}
+}
+
+// Start the service on its own, so it is not stopped with the shell. Services which run as admin are
+// elevated by ShellExecute, and get the environment of the user instead of the configured environment.
+func startDetached(s *shell.Service) error {
+	if s.Admin {
+		return winapi.StartDetachedProcessIn(s.Executable, s.Arguments, s.WorkingDirectory, true)
+	}
+	cmd := exec.Command(s.Executable, s.Arguments...)
+	cmd.Dir = s.WorkingDirectory
+	cmd.Env = append(os.Environ(), s.Environment...)
+	// Console services get a console of their own, like they do when started by ShellExecute
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_CONSOLE | windows.CREATE_NEW_PROCESS_GROUP}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the process when it exits
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Readiness keeps track of which services are ready to handle requests
+type Readiness struct {
+	lock  sync.Mutex
+	ready map[string]chan struct{}
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{ready: map[string]chan struct{}{}}
+}
+
+func (r *Readiness) get(name string) chan struct{} {
+	ch, ok := r.ready[name]
+	if !ok {
+		ch = make(chan struct{})
+		r.ready[name] = ch
+	}
+	return ch
+}
+
+// Mark a service as ready
+func (r *Readiness) Mark(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ch := r.get(name)
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// Mark a service as not ready, e.g. because it was stopped
+func (r *Readiness) Reset(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ch := r.get(name)
+	select {
+	case <-ch:
+		delete(r.ready, name)
+	default:
+	}
+}
+
+// Wait until all of the named services are ready. After timeout, the services
+// which are still not ready are returned in an error.
+func (r *Readiness) Wait(names []string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	expired := false
+	var missing []string
+	for _, name := range names {
+		r.lock.Lock()
+		ch := r.get(name)
+		r.lock.Unlock()
+		if expired {
+			// The deadline only fires once, so only check the remaining services
+			select {
+			case <-ch:
+			default:
+				missing = append(missing, name)
+			}
+			continue
+		}
+		select {
+		case <-ch:
+		case <-deadline:
+			missing = append(missing, name)
+			expired = true
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Not ready after %v: %s", timeout, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	r := NewReadiness()
+	r.Mark("events")
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		r.Mark("driver")
+	}()
+	if err := r.Wait([]string{"events", "driver"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Reset("driver")
+	err := r.Wait([]string{"driver", "events", "hotkeys"}, time.Millisecond*10)
+	if err == nil || err.Error() != "Not ready after 10ms: driver, hotkeys" {
+		t.Fatalf("Expected driver and hotkeys to not be ready, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// Decides whether and when a service is restarted after it exits
+type restarter struct {
+	policy      shell.RestartPolicy
+	initial     time.Duration
+	max         time.Duration
+	multiplier  float64
+	jitter      float64
+	maxRestarts int
+	window      time.Duration
+	// Consecutive restarts of a service which did not stay up for a full window
+	attempt  int
+	restarts []time.Time
+	random   func() float64
+}
+
+func newRestarter(s shell.Service) *restarter {
+	ms := func(v, fallback int) time.Duration {
+		if v <= 0 {
+			v = fallback
+		}
+		return time.Millisecond * time.Duration(v)
+	}
+	r := &restarter{
+		policy:      s.Restart,
+		initial:     ms(s.Backoff.Initial, 500),
+		max:         ms(s.Backoff.Max, 30000),
+		multiplier:  s.Backoff.Multiplier,
+		jitter:      0.2,
+		maxRestarts: s.MaxRestarts,
+		window:      ms(s.RestartWindow, 60000),
+		random:      rand.Float64,
+	}
+	if r.policy == "" {
+		r.policy = shell.RestartNever
+		if s.AutoRestart != nil && *s.AutoRestart {
+			r.policy = shell.RestartAlways
+		}
+	}
+	if r.multiplier < 1 {
+		r.multiplier = 2
+	}
+	if s.Backoff.Jitter != nil {
+		r.jitter = math.Max(0, math.Min(1, *s.Backoff.Jitter))
+	}
+	if r.maxRestarts <= 0 {
+		r.maxRestarts = 5
+	}
+	return r
+}
+
+// Decide what happens after the service exited. If restart is false, the service stays down.
+// If failed is also true, the service is restarting too often and should be marked failed.
+func (r *restarter) next(exitCode int, uptime time.Duration, now time.Time) (delay time.Duration, restart bool, failed bool) {
+	if r.policy == shell.RestartNever || (r.policy == shell.RestartOnFailure && exitCode == 0) {
+		return 0, false, false
+	}
+
+	var recent []time.Time
+	for _, t := range r.restarts {
+		if now.Sub(t) < r.window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= r.maxRestarts {
+		r.restarts = recent
+		return 0, false, true
+	}
+	r.restarts = append(recent, now)
+
+	if uptime >= r.window {
+		r.attempt = 0
+	}
+	base := float64(r.initial) * math.Pow(r.multiplier, float64(r.attempt))
+	base = math.Min(base, float64(r.max))
+	r.attempt += 1
+	delay = time.Duration(base * (1 + r.jitter*(2*r.random()-1)))
+	return delay, true, false
+}
+
+// Forget previous restarts, e.g. when the service is started manually
+func (r *restarter) reset() {
+	r.attempt = 0
+	r.restarts = nil
+}
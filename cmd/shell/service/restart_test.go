@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+func TestRestartPolicies(t *testing.T) {
+	now := time.Now()
+	never := newRestarter(shell.Service{})
+	if _, restart, _ := never.next(1, 0, now); restart {
+		t.Fatalf("Expected services to not be restarted by default")
+	}
+	onFailure := newRestarter(shell.Service{Restart: shell.RestartOnFailure})
+	if _, restart, _ := onFailure.next(0, 0, now); restart {
+		t.Fatalf("Expected a clean exit to not be restarted")
+	}
+	if _, restart, _ := onFailure.next(1, 0, now); !restart {
+		t.Fatalf("Expected a failure to be restarted")
+	}
+	auto := true
+	legacy := newRestarter(shell.Service{AutoRestart: &auto})
+	if _, restart, _ := legacy.next(0, 0, now); !restart {
+		t.Fatalf("Expected AutoRestart to always restart")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	noJitter := 0.0
+	r := newRestarter(shell.Service{
+		Restart:     shell.RestartAlways,
+		Backoff:     shell.Backoff{Initial: 100, Max: 1000, Jitter: &noJitter},
+		MaxRestarts: 100,
+	})
+	now := time.Now()
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, e := range expected {
+		delay, restart, _ := r.next(1, 0, now)
+		if !restart || delay != e*time.Millisecond {
+			t.Fatalf("Restart %d: expected %v, got %v", i, e*time.Millisecond, delay)
+		}
+	}
+	// A service which stayed up for a full window starts over
+	if delay, _, _ := r.next(1, time.Hour, now); delay != 100*time.Millisecond {
+		t.Fatalf("Expected the backoff to be reset, got %v", delay)
+	}
+
+	jitter := 0.5
+	r = newRestarter(shell.Service{Restart: shell.RestartAlways, Backoff: shell.Backoff{Initial: 100, Jitter: &jitter}})
+	r.random = func() float64 { return 0 }
+	if delay, _, _ := r.next(1, 0, now); delay != 50*time.Millisecond {
+		t.Fatalf("Expected jitter to shorten the delay to 50ms, got %v", delay)
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	r := newRestarter(shell.Service{Restart: shell.RestartAlways, MaxRestarts: 3, RestartWindow: 1000})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, restart, failed := r.next(1, 0, now); !restart || failed {
+			t.Fatalf("Restart %d: expected a restart", i)
+		}
+	}
+	if _, restart, failed := r.next(1, 0, now); restart || !failed {
+		t.Fatalf("Expected the service to be marked failed")
+	}
+	// Restarts outside the window are forgotten
+	if _, restart, _ := r.next(1, 0, now.Add(time.Second)); !restart {
+		t.Fatalf("Expected a restart after the window passed")
+	}
+}
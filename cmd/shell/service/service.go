@@ -1,16 +1,20 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
-	"github.com/operdies/windows-nats-shell/pkg/winapi"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+	"github.com/operdies/windows-nats-shell/pkg/utils/cron"
 )
 
 type Jobber interface {
@@ -18,16 +22,73 @@ type Jobber interface {
 	Stop() error
 }
 
+// ProcessJob supervises the process of a service. Its state only changes while lock is held:
+//
+//	stopped, crashed, failed, succeeded -> running   Start, or RunNow of a oneshot service
+//	stopped, crashed, failed, succeeded -> starting  the shell waits for the dependencies of the service
+//	starting, backing-off    -> running              the dependencies are ready, or the backoff elapsed
+//	starting, backing-off    -> stopped              Stop
+//	running                  -> stopping             Stop
+//	stopping                 -> stopped              the process exited
+//	running                  -> backing-off          the process exited, and the restart policy restarts it
+//	running                  -> crashed, stopped     the process exited, and the restart policy does not restart it
+//	running                  -> succeeded            the process of a oneshot service exited cleanly
+//	running                  -> failed               the process exited, and it restarted too often
+//
+// Scheduled services are oneshot services which are not started by Start. Instead, Start resumes their schedule:
+//
+//	stopped                               -> scheduled  Start
+//	scheduled, crashed, failed, succeeded -> running    the next run is due, or RunNow
+//	running                               -> scheduled  the process exited cleanly
+//	scheduled, crashed, failed, succeeded -> stopped    Stop
+//
+// Disabled and detached services never change state.
 type ProcessJob struct {
-	restart    bool
-	StartCount int
-	service    *shell.Service
-	cmd        *exec.Cmd
-	name       string
+	// Called when the shell gives up on the service, or it becomes unhealthy
+	Toast func(shell.Toast)
+	// Used for shutdown requests and health checks
+	Requester *client.Requester
+	// Called whenever the process of a oneshot service exits, or fails to start
+	Completed func(shell.ServiceRun)
+	// Called when the process of the service starts or exits, and when it is restarted or failed
+	Events func(shell.ServiceEvent)
+	// Called when a oneshot service succeeds, which makes it ready
+	succeeded func()
+	// Called before each scheduled run, and blocks until the dependencies of the service are ready
+	awaitDependencies func()
+
+	lock  sync.Mutex
+	state shell.ServiceState
+	// Incremented by every transition, so scheduled starts and restarts can tell if they are stale
+	generation int
+	startCount int
+	// Set while the health check of the service is failing
+	unhealthy bool
+	restarter *restarter
+	pending   *time.Timer
+	// The schedule of a scheduled service, and the timer of its next run while the schedule is active
+	crontab *cron.Schedule
+	timer   *time.Timer
+	nextRun time.Time
+	started time.Time
+	// The outcome of the last time the service exited or failed to start
+	lastExitCode int
+	lastError    string
+	service      *shell.Service
+	nats         shell.NatsConfig
+	cmd          *exec.Cmd
+	// The processes of the service, and the processes it started
+	group processGroup
+	// Closed when the process exits
+	done chan struct{}
+	name string
 }
 
+const defaultGracePeriod = 5000
+
 func withTimeout[T any](f func() T, timeout time.Duration) (result T, err error) {
-	r := make(chan T)
+	// Buffered, so the goroutine can finish after the timeout
+	r := make(chan T, 1)
 	go func() {
 		r <- f()
 	}()
@@ -56,122 +117,426 @@ func CombineErrors(errors ...error) error {
 	return err
 }
 
-type NatsStdout struct {
-	subject string
-	nc      *nats.Conn
+// Start the service, and forget whether it failed previously.
+// Scheduled services are not started, but resume their schedule.
+func (j *ProcessJob) Start() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.crontab != nil {
+		j.resume()
+		return nil
+	}
+	j.restarter.reset()
+	return j.spawn()
 }
 
-func CreateNatsStdout(subject string) *NatsStdout {
-	var n NatsStdout
-	n.subject = "stdout." + subject
-	n.nc, _ = nats.Connect(nats.DefaultURL)
-	return &n
+// Run a oneshot service immediately. Scheduled services keep their schedule.
+func (j *ProcessJob) RunNow() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if !j.service.IsOneshot() {
+		return reply.Errorf(reply.InvalidRequest, "Service '%s' is not a oneshot service.", j.name)
+	}
+	if j.state == shell.StateDisabled {
+		return reply.Errorf(reply.InvalidRequest, "Service '%s' is not enabled.", j.name)
+	}
+	j.restarter.reset()
+	return j.spawn()
 }
 
-func (n *NatsStdout) Close() {
-	n.nc.Close()
+// Activate the schedule of a scheduled service. Must be called with lock held.
+func (j *ProcessJob) resume() {
+	if j.timer != nil || j.state == shell.StateDisabled {
+		return
+	}
+	if j.state == shell.StateStopped {
+		j.state = shell.StateScheduled
+	}
+	j.arm()
 }
 
-func (n *NatsStdout) Write(data []byte) (int, error) {
-	n.nc.Publish(n.subject, data)
-	return len(data), nil
+// Start the timer of the next scheduled run. Must be called with lock held.
+func (j *ProcessJob) arm() {
+	j.nextRun = j.crontab.Next(time.Now())
+	if j.nextRun.IsZero() {
+		log.Printf("The schedule of %s never matches.\n", j.name)
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(j.nextRun), func() {
+		// Wait without the lock, so the service can be stopped in the meantime
+		if j.awaitDependencies != nil {
+			j.awaitDependencies()
+		}
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		if j.timer != timer {
+			return
+		}
+		switch j.state {
+		case shell.StateRunning, shell.StateStopping, shell.StateBackingOff, shell.StateStarting:
+			log.Printf("Skipping scheduled run of %s, since the previous run has not completed.\n", j.name)
+		default:
+			j.restarter.reset()
+			j.spawn()
+		}
+		j.arm()
+	})
+	j.timer = timer
 }
 
-func (j *ProcessJob) Start() error {
-	if j.service.Executable == "" {
-		return fmt.Errorf("Service %s has no configured executable.", j.name)
+// Deactivate the schedule of a scheduled service. Returns false if it was not active. Must be called with lock held.
+func (j *ProcessJob) disarm() bool {
+	if j.timer == nil {
+		return false
+	}
+	j.timer.Stop()
+	j.timer = nil
+	j.nextRun = time.Time{}
+	return true
+}
+
+// Report a completed run of a oneshot service. Must be called with lock held.
+func (j *ProcessJob) complete(run shell.ServiceRun) {
+	if !j.service.IsOneshot() {
+		return
+	}
+	if run.Succeeded() && j.succeeded != nil {
+		j.succeeded()
+	}
+	if j.Completed != nil {
+		j.Completed(run)
 	}
-	if j.service.Enabled != nil && *j.service.Enabled == false {
+}
+
+// Report a lifecycle event. Must be called with lock held.
+func (j *ProcessJob) emit(event shell.ServiceEvent) {
+	if j.Events == nil {
+		return
+	}
+	event.Name = j.name
+	event.StartCount = j.startCount
+	j.Events(event)
+}
+
+// Get the name of the signal which terminated a process, if any
+func exitSignal(state *os.ProcessState) string {
+	status, ok := state.Sys().(interface {
+		Signaled() bool
+		Signal() syscall.Signal
+	})
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	return status.Signal().String()
+}
+
+// Check if the service never signals that it is ready. Oneshot services are ready once they succeed.
+func (j *ProcessJob) silent() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	switch j.state {
+	case shell.StateDisabled, shell.StateDetached:
+		return true
+	}
+	return j.cmd == nil && !j.service.IsOneshot()
+}
+
+// Mark the service as starting while it waits for its dependencies. The returned generation
+// is passed to startScheduled, which does nothing if the service was started or stopped in the meantime.
+func (j *ProcessJob) schedule() int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	switch j.state {
+	case shell.StateStopped, shell.StateCrashed, shell.StateFailed, shell.StateSucceeded, shell.StateBackingOff:
+		j.cancelPending()
+		j.state = shell.StateStarting
+	}
+	j.generation += 1
+	return j.generation
+}
+
+func (j *ProcessJob) startScheduled(generation int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.generation != generation {
 		return nil
 	}
+	j.restarter.reset()
+	return j.spawn()
+}
 
-	if j.service.Detach {
-		return winapi.StartDetachedProcess(j.service.Executable, j.service.Admin)
+func (j *ProcessJob) cancelPending() {
+	if j.pending != nil {
+		j.pending.Stop()
+		j.pending = nil
 	}
+}
 
-	if j.cmd != nil {
+// Start the process of the service. Must be called with lock held.
+func (j *ProcessJob) spawn() error {
+	if j.service.Executable == "" {
+		return fmt.Errorf("Service %s has no configured executable.", j.name)
+	}
+	switch j.state {
+	case shell.StateDisabled:
+		return nil
+	case shell.StateDetached:
+		return startDetached(j.service)
+	case shell.StateRunning, shell.StateStopping:
 		return fmt.Errorf("Process %s is already running.", j.name)
 	}
-	j.StartCount += 1
-	log.Printf("Starting %s. (%d)\n", j.name, j.StartCount)
+	j.cancelPending()
+	j.generation += 1
+	j.startCount += 1
+	log.Printf("Starting %s. (%d)\n", j.name, j.startCount)
 
-	j.restart = *j.service.AutoRestart == true
 	prog := j.service
 	cmd := exec.Command(prog.Executable, prog.Arguments...)
 	ref := fmt.Sprintf("%s=%s", shell.SERVICE_ENV_KEY, j.name)
 	env := os.Environ()
 	env = append(env, prog.Environment...)
+	env = append(env, j.nats.Environment()...)
 	env = append(env, ref)
 	cmd.Env = env
 	cmd.Dir = prog.WorkingDirectory
-	natsStdout := CreateNatsStdout(j.name)
-	natsStderr := CreateNatsStdout(j.name)
-	cmd.Stdout = natsStdout
-	cmd.Stderr = natsStderr
-
-	if j.service.Visible == false {
-		cmd.SysProcAttr = &syscall.SysProcAttr{}
-		cmd.SysProcAttr.HideWindow = true
+	output := connectOutput(j.nats)
+	stdout := NewNatsWriter(output, logs.Subject(logs.Stdout, j.name))
+	stderr := NewNatsWriter(output, logs.Subject(logs.Stderr, j.name))
+	failed := func(err error) error {
+		output.Close()
+		log.Printf("Process %s failed to start. %v\n", j.name, err.Error())
+		j.state = shell.StateCrashed
+		j.lastError = err.Error()
+		j.complete(shell.ServiceRun{Name: j.name, Started: time.Now(), ExitCode: -1, Error: j.lastError})
+		j.emit(shell.ServiceEvent{Event: shell.ServiceExited, ExitCode: -1, Error: j.lastError})
+		return err
+	}
+	pipes, err := pipeOutput(cmd, stdout, stderr)
+	if err != nil {
+		return failed(err)
+	}
+	configure(cmd, prog)
+	var group processGroup = &singleProcess{}
+	if !prog.KeepChildren {
+		group = newProcessGroup(cmd)
 	}
 
-	err := cmd.Start()
-
+	err = cmd.Start()
+	pipes.started()
 	if err != nil {
-		log.Printf("Process %s failed to start. %v\n", j.name, err.Error())
-		j.restart = false
-		return err
+		pipes.wait(outputTimeout)
+		return failed(err)
 	}
 
+	if err := group.add(cmd.Process); err != nil {
+		log.Printf("Processes started by %s will not be stopped with it. %v\n", j.name, err)
+	}
+	j.state = shell.StateRunning
 	j.cmd = cmd
+	j.group = group
+	j.unhealthy = false
+	j.started = time.Now()
+	j.done = make(chan struct{})
+	j.emit(shell.ServiceEvent{Event: shell.ServiceStarted, Pid: cmd.Process.Pid})
+	go j.watchHealth(j.done)
 
-	go func() {
-		defer natsStdout.Close()
-		defer natsStderr.Close()
+	go func(done chan struct{}) {
 		err := cmd.Wait()
+		group.release()
+		pipes.wait(outputTimeout)
+		// Publish the last line, even if it was not terminated
+		stdout.Flush()
+		stderr.Flush()
+		output.Close()
 		ex := cmd.ProcessState.ExitCode()
-		j.cmd = nil
 		if err != nil {
 			log.Printf("Process %s exited. (%d: %v)\n", j.name, ex, err)
 		} else {
 			log.Printf("Process %s exited. (%d)\n", j.name, ex)
 		}
 
-		if j.restart {
-			j.Start()
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		j.cmd = nil
+		j.group = nil
+		j.lastExitCode = ex
+		j.lastError = ""
+		if err != nil {
+			j.lastError = err.Error()
 		}
-	}()
+		close(done)
+		uptime := time.Since(j.started)
+		j.complete(shell.ServiceRun{Name: j.name, Started: j.started, Duration: uptime, ExitCode: ex, Error: j.lastError})
+		j.emit(shell.ServiceEvent{
+			Event:    shell.ServiceExited,
+			Pid:      cmd.Process.Pid,
+			ExitCode: ex,
+			Signal:   exitSignal(cmd.ProcessState),
+			Uptime:   uptime,
+			Error:    j.lastError,
+		})
+		j.exited(ex, uptime)
+	}(j.done)
 
-	return err
+	return nil
+}
+
+// Decide the next state after the process exited. Must be called with lock held.
+func (j *ProcessJob) exited(exitCode int, uptime time.Duration) {
+	j.generation += 1
+	if j.state == shell.StateStopping {
+		j.state = shell.StateStopped
+		return
+	}
+	delay, restart, failed := j.restarter.next(exitCode, uptime, time.Now())
+	switch {
+	case failed:
+		j.state = shell.StateFailed
+		msg := fmt.Sprintf("%s restarted %d times within %v. Giving up.", j.name, j.restarter.maxRestarts, j.restarter.window)
+		log.Println(msg)
+		if j.Toast != nil {
+			j.Toast(shell.Toast{Title: fmt.Sprintf("%s failed", j.name), Message: msg, Level: shell.Critical, Duration: -1})
+		}
+		j.emit(shell.ServiceEvent{Event: shell.ServiceFailed, Restarts: len(j.restarter.restarts), Error: msg})
+	case restart:
+		j.state = shell.StateBackingOff
+		log.Printf("Restarting %s in %v.\n", j.name, delay)
+		j.emit(shell.ServiceEvent{Event: shell.ServiceRestarting, Restarts: len(j.restarter.restarts), Delay: delay})
+		generation := j.generation
+		j.pending = time.AfterFunc(delay, func() {
+			j.lock.Lock()
+			defer j.lock.Unlock()
+			if j.generation != generation {
+				return
+			}
+			j.pending = nil
+			if err := j.spawn(); err != nil {
+				j.exited(-1, 0)
+			}
+		})
+	case exitCode != 0 || j.lastError != "":
+		j.state = shell.StateCrashed
+	case j.timer != nil:
+		j.state = shell.StateScheduled
+	case j.service.IsOneshot():
+		j.state = shell.StateSucceeded
+	default:
+		j.state = shell.StateStopped
+	}
 }
 
+// Get the current state of the service
+func (j *ProcessJob) Status() shell.ServiceStatusInfo {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	status := shell.ServiceStatusInfo{
+		Name:         j.name,
+		State:        j.state,
+		StartCount:   j.startCount,
+		Unhealthy:    j.unhealthy,
+		LastExitCode: j.lastExitCode,
+		LastError:    j.lastError,
+		NextRun:      j.nextRun,
+	}
+	if j.cmd != nil {
+		status.Pid = j.cmd.Process.Pid
+		status.Uptime = time.Since(j.started)
+	}
+	return status
+}
+
+// Check if the process of the service is running
+func (j *ProcessJob) Running() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.cmd != nil
+}
+
+// Stop the service, and wait for its process to exit. Scheduled services also stop their schedule.
 func (j *ProcessJob) Stop() (err error) {
-	j.restart = false
-	if j.cmd == nil {
+	j.lock.Lock()
+	paused := j.disarm()
+	switch j.state {
+	case shell.StateStarting, shell.StateBackingOff:
+		j.cancelPending()
+		j.generation += 1
+		j.state = shell.StateStopped
+		j.lock.Unlock()
+		return nil
+	case shell.StateRunning:
+	default:
+		if paused {
+			j.state = shell.StateStopped
+			j.lock.Unlock()
+			return nil
+		}
+		j.lock.Unlock()
 		return fmt.Errorf("Process %s is not running.", j.name)
 	}
-	killError := j.cmd.Process.Kill()
-	waitErr, timeoutErr := withTimeout(j.cmd.Wait, time.Second*3)
+	j.state = shell.StateStopping
+	j.generation += 1
+	group, done := j.group, j.done
+	j.lock.Unlock()
 
-	// the process is dead
-	if timeoutErr == nil {
-		j.cmd = nil
+	if j.shutdown(done) {
+		return nil
 	}
+	log.Printf("Killing %s.\n", j.name)
+	killError := group.kill()
+	_, timeoutErr := withTimeout(func() struct{} { return <-done }, time.Second*3)
 
-	err = CombineErrors(killError, waitErr, timeoutErr)
+	err = CombineErrors(killError, timeoutErr)
 	return
 }
 
-func NewProcessJob(name string, service shell.Service) *ProcessJob {
+// Ask the service to shut down, and wait for the grace period for it to exit.
+// Returns false if the service must be killed.
+func (j *ProcessJob) shutdown(done chan struct{}) bool {
+	grace := j.service.GracePeriod
+	if grace == 0 {
+		grace = defaultGracePeriod
+	}
+	if grace < 0 || j.Requester == nil {
+		return false
+	}
+	timeout := time.Millisecond * time.Duration(grace)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := j.Requester.Instance(j.name).Shutdown(ctx); err != nil {
+		log.Printf("%s did not accept shutdown request. (%v)\n", j.name, err)
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		log.Printf("%s did not exit within %v.\n", j.name, timeout)
+		return false
+	}
+}
+
+func NewProcessJob(name string, service shell.Service, nats shell.NatsConfig) *ProcessJob {
 	s := ProcessJob{}
 	s.service = &service
+	s.nats = nats
 	s.name = name
-	if service.AutoRestart == nil {
-		b := false
-		service.AutoRestart = &b
+	s.restarter = newRestarter(service)
+	s.state = shell.StateStopped
+	if schedule, err := service.ParseSchedule(); err != nil {
+		log.Printf("Service %s is not scheduled. %v\n", name, err)
+	} else {
+		s.crontab = schedule
 	}
 	if service.Enabled == nil {
 		b := true
 		service.Enabled = &b
 	}
+	if !*service.Enabled {
+		s.state = shell.StateDisabled
+	} else if service.Detach {
+		s.state = shell.StateDetached
+	}
 	return &s
 }
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// The test binary doubles as the process of the services started by the tests
+const helperKey = "_SERVICE_TEST_HELPER_"
+
+// The file the 'tree' and 'orphan' helpers write the pid of their child to
+const pidKey = "_SERVICE_TEST_PIDFILE_"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperKey) {
+	case "":
+		os.Exit(m.Run())
+	case "run":
+		time.Sleep(time.Hour)
+	case "crash":
+		os.Exit(3)
+	case "exit":
+		os.Exit(0)
+	case "tree", "orphan":
+		// Start a child which may outlive this process, and report its pid
+		child := exec.Command(os.Args[0])
+		child.Env = append(os.Environ(), helperKey+"=run")
+		if err := child.Start(); err != nil {
+			os.Exit(1)
+		}
+		os.WriteFile(os.Getenv(pidKey), []byte(strconv.Itoa(child.Process.Pid)), 0644)
+		if os.Getenv(helperKey) == "tree" {
+			time.Sleep(time.Hour)
+		}
+	}
+}
+
+// A service which runs the test binary as a helper process
+func helper(behavior string) shell.Service {
+	return shell.Service{
+		Executable:  os.Args[0],
+		Environment: []string{helperKey + "=" + behavior},
+		GracePeriod: -1,
+		Backoff:     shell.Backoff{Initial: 10, Max: 10},
+	}
+}
+
+func waitFor(t *testing.T, j *ProcessJob, state shell.ServiceState) shell.ServiceStatusInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := j.Status()
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be %s, got %+v", j.name, state, status)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	j := NewProcessJob("run", helper("run"), shell.NatsConfig{})
+	if err := j.Stop(); err == nil {
+		t.Fatalf("Expected stopping a stopped service to fail")
+	}
+	if err := j.Start(); err != nil {
+		t.Fatal(err)
+	}
+	status := waitFor(t, j, shell.StateRunning)
+	if status.Pid == 0 || status.StartCount != 1 {
+		t.Fatalf("Expected a running process, got %+v", status)
+	}
+	if err := j.Start(); err == nil {
+		t.Fatalf("Expected starting a running service to fail")
+	}
+	if err := j.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status := j.Status(); status.State != shell.StateStopped || j.Running() {
+		t.Fatalf("Expected the service to be stopped, got %+v", status)
+	}
+	if err := j.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitFor(t, j, shell.StateRunning); status.StartCount != 2 {
+		t.Fatalf("Expected a second start, got %+v", status)
+	}
+	j.Stop()
+}
+
+func TestJobExits(t *testing.T) {
+	crash := NewProcessJob("crash", helper("crash"), shell.NatsConfig{})
+	crash.Start()
+	if status := waitFor(t, crash, shell.StateCrashed); status.LastExitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %+v", status)
+	}
+
+	s := helper("exit")
+	s.Restart = shell.RestartOnFailure
+	exit := NewProcessJob("exit", s, shell.NatsConfig{})
+	exit.Start()
+	if status := waitFor(t, exit, shell.StateStopped); status.StartCount != 1 {
+		t.Fatalf("Expected a clean exit to not be restarted, got %+v", status)
+	}
+
+	missing := NewProcessJob("missing", shell.Service{Executable: "./does-not-exist"}, shell.NatsConfig{})
+	if err := missing.Start(); err == nil {
+		t.Fatalf("Expected a missing executable to fail")
+	}
+	if status := missing.Status(); status.State != shell.StateCrashed || status.LastError == "" {
+		t.Fatalf("Expected the failed start to be recorded, got %+v", status)
+	}
+}
+
+func TestJobRestartsUntilFailed(t *testing.T) {
+	s := helper("crash")
+	s.Restart = shell.RestartOnFailure
+	s.MaxRestarts = 3
+	j := NewProcessJob("crash", s, shell.NatsConfig{})
+	j.Start()
+	if status := waitFor(t, j, shell.StateFailed); status.StartCount != 4 {
+		t.Fatalf("Expected 3 restarts, got %+v", status)
+	}
+
+	// Starting the service manually forgets that it failed
+	j.Start()
+	if status := waitFor(t, j, shell.StateFailed); status.StartCount != 8 {
+		t.Fatalf("Expected 3 more restarts, got %+v", status)
+	}
+}
+
+func TestStopWhileBackingOff(t *testing.T) {
+	s := helper("crash")
+	s.Restart = shell.RestartAlways
+	s.Backoff = shell.Backoff{Initial: 100, Max: 100}
+	j := NewProcessJob("crash", s, shell.NatsConfig{})
+	j.Start()
+	waitFor(t, j, shell.StateBackingOff)
+	if err := j.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if status := j.Status(); status.State != shell.StateStopped || status.StartCount != 1 {
+		t.Fatalf("Expected the pending restart to be canceled, got %+v", status)
+	}
+}
+
+func TestScheduledStart(t *testing.T) {
+	j := NewProcessJob("run", helper("run"), shell.NatsConfig{})
+	generation := j.schedule()
+	if status := j.Status(); status.State != shell.StateStarting {
+		t.Fatalf("Expected the service to be starting, got %+v", status)
+	}
+	j.Stop()
+	j.startScheduled(generation)
+	if status := j.Status(); status.State != shell.StateStopped || status.StartCount != 0 {
+		t.Fatalf("Expected the scheduled start to be abandoned, got %+v", status)
+	}
+
+	j.startScheduled(j.schedule())
+	waitFor(t, j, shell.StateRunning)
+	j.Stop()
+}
+
+func TestOneshotJob(t *testing.T) {
+	s := helper("exit")
+	s.Kind = shell.KindOneshot
+	j := NewProcessJob("oneshot", s, shell.NatsConfig{})
+	runs := make(chan shell.ServiceRun, 10)
+	j.Completed = func(run shell.ServiceRun) { runs <- run }
+
+	j.Start()
+	waitFor(t, j, shell.StateSucceeded)
+	if run := <-runs; !run.Succeeded() || run.Name != "oneshot" || run.Started.IsZero() {
+		t.Fatalf("Expected a successful run, got %+v", run)
+	}
+	if err := j.RunNow(); err != nil {
+		t.Fatal(err)
+	}
+	if run := <-runs; !run.Succeeded() || j.Status().StartCount != 2 {
+		t.Fatalf("Expected a second successful run, got %+v", run)
+	}
+
+	crash := helper("crash")
+	crash.Kind = shell.KindOneshot
+	j = NewProcessJob("crash", crash, shell.NatsConfig{})
+	j.Completed = func(run shell.ServiceRun) { runs <- run }
+	j.RunNow()
+	waitFor(t, j, shell.StateCrashed)
+	if run := <-runs; run.Succeeded() || run.ExitCode != 3 {
+		t.Fatalf("Expected a failed run, got %+v", run)
+	}
+
+	daemon := NewProcessJob("run", helper("run"), shell.NatsConfig{})
+	if err := daemon.RunNow(); !reply.IsCode(err, reply.InvalidRequest) {
+		t.Fatalf("Expected only oneshot services to run now, got %v", err)
+	}
+}
+
+func TestScheduledJob(t *testing.T) {
+	s := helper("exit")
+	s.Schedule = "@every 50ms"
+	j := NewProcessJob("scheduled", s, shell.NatsConfig{})
+	j.Start()
+	status := j.Status()
+	if status.State != shell.StateScheduled || status.NextRun.IsZero() || status.StartCount != 0 {
+		t.Fatalf("Expected the service to wait for its first run, got %+v", status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for j.Status().StartCount < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the service to run twice, got %+v", j.Status())
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	waitFor(t, j, shell.StateScheduled)
+
+	if err := j.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	count := j.Status().StartCount
+	time.Sleep(time.Millisecond * 150)
+	if status := j.Status(); status.State != shell.StateStopped || status.StartCount != count || !status.NextRun.IsZero() {
+		t.Fatalf("Expected the schedule to be stopped, got %+v", status)
+	}
+}
+
+func TestScheduledRunsDoNotOverlap(t *testing.T) {
+	s := helper("run")
+	s.Schedule = "@every 20ms"
+	j := NewProcessJob("slow", s, shell.NatsConfig{})
+	j.Start()
+	waitFor(t, j, shell.StateRunning)
+	time.Sleep(time.Millisecond * 100)
+	if status := j.Status(); status.StartCount != 1 {
+		t.Fatalf("Expected runs to be skipped while the service is running, got %+v", status)
+	}
+	j.Stop()
+}
+
+func TestJobEvents(t *testing.T) {
+	s := helper("crash")
+	s.Restart = shell.RestartOnFailure
+	s.MaxRestarts = 1
+	j := NewProcessJob("crash", s, shell.NatsConfig{})
+	events := make(chan shell.ServiceEvent, 10)
+	j.Events = func(e shell.ServiceEvent) { events <- e }
+	j.Start()
+	waitFor(t, j, shell.StateFailed)
+
+	expected := []string{shell.ServiceStarted, shell.ServiceExited, shell.ServiceRestarting, shell.ServiceStarted, shell.ServiceExited, shell.ServiceFailed}
+	for i, subject := range expected {
+		e := <-events
+		if e.Event != subject || e.Name != "crash" {
+			t.Fatalf("Expected event %d to be %s, got %+v", i+1, subject, e)
+		}
+		switch e.Event {
+		case shell.ServiceStarted:
+			if e.Pid == 0 || e.StartCount != i/3+1 {
+				t.Fatalf("Expected the pid and start count, got %+v", e)
+			}
+		case shell.ServiceExited:
+			if e.ExitCode != 3 || e.Pid == 0 || e.Uptime <= 0 || e.Signal != "" {
+				t.Fatalf("Expected exit code 3, got %+v", e)
+			}
+		case shell.ServiceRestarting:
+			if e.Delay <= 0 || e.Restarts != 1 {
+				t.Fatalf("Expected a restart delay, got %+v", e)
+			}
+		case shell.ServiceFailed:
+			if e.Restarts != 1 || e.Error == "" {
+				t.Fatalf("Expected the reason the service failed, got %+v", e)
+			}
+		}
+	}
+
+	// Killed processes report the signal, on platforms with signals
+	j = NewProcessJob("run", helper("run"), shell.NatsConfig{})
+	j.Events = func(e shell.ServiceEvent) { events <- e }
+	j.Start()
+	<-events
+	j.Stop()
+	e := <-events
+	if e.Event != shell.ServiceExited || (runtime.GOOS != "windows" && e.Signal != "killed") {
+		t.Fatalf("Expected the service to be killed, got %+v", e)
+	}
+}
+
+func TestConcurrentJobOperations(t *testing.T) {
+	s := helper("crash")
+	s.Restart = shell.RestartAlways
+	s.MaxRestarts = 1000
+	j := NewProcessJob("crash", s, shell.NatsConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < 20; k++ {
+				switch (i + k) % 4 {
+				case 0:
+					j.Start()
+				case 1:
+					j.Stop()
+				case 2:
+					j.startScheduled(j.schedule())
+				case 3:
+					j.Status()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The service settles once it is stopped
+	for j.Stop() == nil {
+	}
+	state := j.Status().State
+	if state != shell.StateStopped && state != shell.StateCrashed {
+		t.Fatalf("Expected the service to be stopped, got %s", state)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if status := j.Status(); status.State != state || j.Running() {
+		t.Fatalf("Expected the service to stay %s, got %+v", state, status)
+	}
+}
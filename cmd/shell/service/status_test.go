@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+func TestStatus(t *testing.T) {
+	disabled := false
+	cases := []struct {
+		service  shell.Service
+		prepare  func(j *ProcessJob)
+		expected shell.ServiceState
+	}{
+		{shell.Service{}, func(j *ProcessJob) {}, shell.StateStopped},
+		{shell.Service{Enabled: &disabled}, func(j *ProcessJob) {}, shell.StateDisabled},
+		{shell.Service{Detach: true}, func(j *ProcessJob) {}, shell.StateDetached},
+		{shell.Service{}, func(j *ProcessJob) { j.state = shell.StateRunning; j.exited(2, 0) }, shell.StateCrashed},
+		{shell.Service{}, func(j *ProcessJob) { j.state = shell.StateStopping; j.exited(1, 0) }, shell.StateStopped},
+		{shell.Service{Restart: shell.RestartAlways, MaxRestarts: 1}, func(j *ProcessJob) {
+			j.restarter.next(1, 0, time.Now())
+			j.state = shell.StateRunning
+			j.exited(1, 0)
+		}, shell.StateFailed},
+	}
+	for i, c := range cases {
+		j := NewProcessJob("test", c.service, shell.NatsConfig{})
+		c.prepare(j)
+		if status := j.Status(); status.State != c.expected || status.Name != "test" {
+			t.Fatalf("Case %d: expected %s, got %+v", i, c.expected, status)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// Supervisor owns the jobs of the configured services
+type Supervisor struct {
+	// Called for every job before it is started, e.g. to set its requester
+	Prepare func(job *ProcessJob)
+	// How long a service waits for the services it depends on
+	ReadyTimeout time.Duration
+
+	// Serializes operations which start or stop jobs, which may take a while
+	ops sync.Mutex
+	// Protects config and jobs, and is only held briefly
+	lock      sync.RWMutex
+	config    *shell.Configuration
+	jobs      map[string]*ProcessJob
+	readiness *Readiness
+}
+
+func NewSupervisor(config *shell.Configuration) *Supervisor {
+	return &Supervisor{
+		ReadyTimeout: 10 * time.Second,
+		config:       config,
+		jobs:         map[string]*ProcessJob{},
+		readiness:    NewReadiness(),
+	}
+}
+
+// Get the current config. It must not be modified.
+func (s *Supervisor) Config() *shell.Configuration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.config
+}
+
+// Mark a service as ready to handle requests
+func (s *Supervisor) Ready(name string) {
+	s.readiness.Mark(name)
+}
+
+func (s *Supervisor) job(name string) (*ProcessJob, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if job, ok := s.jobs[name]; ok {
+		return job, nil
+	}
+	return nil, reply.Errorf(reply.NotConfigured, "Service '%s' is not configured.", name)
+}
+
+func (s *Supervisor) newJob(name string) *ProcessJob {
+	job := NewProcessJob(name, s.config.Services[name], s.config.Nats)
+	job.succeeded = func() { s.readiness.Mark(name) }
+	job.awaitDependencies = func() {
+		s.lock.RLock()
+		dependencies := s.config.Services[name].DependsOn
+		s.lock.RUnlock()
+		if err := s.readiness.Wait(dependencies, s.ReadyTimeout); err != nil {
+			log.Printf("Running %s anyway. %v\n", name, err)
+		}
+	}
+	if s.Prepare != nil {
+		s.Prepare(job)
+	}
+	return job
+}
+
+// Start a job once the services it depends on are ready
+func (s *Supervisor) launch(name string, job *ProcessJob) {
+	if job.crontab != nil {
+		// Scheduled services run on their own, so they never hold up the services which depend on them.
+		// They wait for their own dependencies before each run instead.
+		job.Start()
+		s.readiness.Mark(name)
+		return
+	}
+	s.lock.RLock()
+	dependencies := s.config.Services[name].DependsOn
+	s.lock.RUnlock()
+	generation := job.schedule()
+	go func() {
+		if err := s.readiness.Wait(dependencies, s.ReadyTimeout); err != nil {
+			log.Printf("Starting %s anyway. %v\n", name, err)
+		}
+		job.startScheduled(generation)
+		if job.silent() {
+			s.readiness.Mark(name)
+		}
+	}()
+}
+
+// Start every service in dependency order
+func (s *Supervisor) StartAll() error {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	order, err := s.Config().StartOrder()
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		job := s.newJob(name)
+		s.lock.Lock()
+		s.jobs[name] = job
+		s.lock.Unlock()
+		s.launch(name, job)
+	}
+	return nil
+}
+
+// Stop every service in the reverse order they were started in
+func (s *Supervisor) StopAll() {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	order, _ := s.Config().StartOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		s.stop(order[i])
+	}
+}
+
+func (s *Supervisor) stop(name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	s.readiness.Reset(name)
+	return job.Stop()
+}
+
+// Start a service immediately
+func (s *Supervisor) Start(name string) error {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	return job.Start()
+}
+
+// Run a oneshot service immediately
+func (s *Supervisor) RunNow(name string) error {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	return job.RunNow()
+}
+
+// Stop a service. It will not be restarted until it is started again.
+func (s *Supervisor) Stop(name string) error {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	return s.stop(name)
+}
+
+// Stop a service if it is running, and start it again once its dependencies are ready
+func (s *Supervisor) Restart(name string) error {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	s.stop(name)
+	s.launch(name, job)
+	return nil
+}
+
+// Derive a new config from the current config, and only restart the services whose config changed.
+// Updates are serialized, so the config does not change while update runs. Changes to the NATS
+// config are not applied, since the shell must reconnect. The shell should restart instead.
+func (s *Supervisor) Update(update func(current *shell.Configuration) (*shell.Configuration, error)) (shell.ConfigDiff, error) {
+	s.ops.Lock()
+	defer s.ops.Unlock()
+	current := s.Config()
+	next, err := update(current)
+	if err != nil {
+		return shell.ConfigDiff{}, err
+	}
+	diff := current.Diff(next)
+	if diff.Nats {
+		return diff, nil
+	}
+	if !diff.Empty() {
+		log.Printf("Applying config. %+v\n", diff)
+	}
+
+	stale := map[string]bool{}
+	for _, name := range append(diff.Removed, diff.Changed...) {
+		stale[name] = true
+	}
+	order, _ := current.StartOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		if stale[order[i]] {
+			s.stop(order[i])
+		}
+	}
+
+	s.lock.Lock()
+	s.config = next
+	for _, name := range diff.Removed {
+		delete(s.jobs, name)
+	}
+	s.lock.Unlock()
+
+	fresh := map[string]bool{}
+	for _, name := range append(diff.Added, diff.Changed...) {
+		fresh[name] = true
+	}
+	order, _ = next.StartOrder()
+	for _, name := range order {
+		if !fresh[name] {
+			continue
+		}
+		job := s.newJob(name)
+		s.lock.Lock()
+		if old, ok := s.jobs[name]; ok {
+			job.startCount = old.Status().StartCount
+		}
+		s.jobs[name] = job
+		s.lock.Unlock()
+		s.launch(name, job)
+	}
+	return diff, nil
+}
+
+// Get the status of a service
+func (s *Supervisor) Status(name string) (shell.ServiceStatusInfo, error) {
+	job, err := s.job(name)
+	if err != nil {
+		return shell.ServiceStatusInfo{}, err
+	}
+	return job.Status(), nil
+}
+
+// Get the status of every service, sorted by name
+func (s *Supervisor) List() []shell.ServiceStatusInfo {
+	s.lock.RLock()
+	jobs := make([]*ProcessJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.lock.RUnlock()
+
+	result := make([]shell.ServiceStatusInfo, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, job.Status())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/utils"
+)
+
+func supervise(t *testing.T, services map[string]shell.Service) *Supervisor {
+	t.Helper()
+	s := NewSupervisor(&shell.Configuration{Services: services})
+	s.ReadyTimeout = 5 * time.Second
+	if err := s.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.StopAll)
+	return s
+}
+
+func waitForService(t *testing.T, s *Supervisor, name string, state shell.ServiceState) shell.ServiceStatusInfo {
+	t.Helper()
+	job, err := s.job(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return waitFor(t, job, state)
+}
+
+func TestSupervisorDependencies(t *testing.T) {
+	driver := helper("run")
+	driver.DependsOn = []string{"events"}
+	s := supervise(t, map[string]shell.Service{
+		"events": helper("run"),
+		"driver": driver,
+	})
+
+	waitForService(t, s, "events", shell.StateRunning)
+	if status, _ := s.Status("driver"); status.State != shell.StateStarting {
+		t.Fatalf("Expected driver to wait for events, got %+v", status)
+	}
+	s.Ready("events")
+	waitForService(t, s, "driver", shell.StateRunning)
+
+	// A restart waits for the dependencies again
+	s.Stop("events")
+	s.Restart("driver")
+	if status, _ := s.Status("driver"); status.State != shell.StateStarting {
+		t.Fatalf("Expected driver to wait for events, got %+v", status)
+	}
+	// Stopping a starting service abandons the start
+	s.Stop("driver")
+	s.Start("events")
+	s.Ready("events")
+	time.Sleep(time.Millisecond * 50)
+	if status, _ := s.Status("driver"); status.State != shell.StateStopped {
+		t.Fatalf("Expected driver to stay stopped, got %+v", status)
+	}
+
+	if _, err := s.Status("hotkeys"); !reply.IsCode(err, reply.NotConfigured) {
+		t.Fatalf("Expected %s, got %v", reply.NotConfigured, err)
+	}
+}
+
+func TestSupervisorOneshotDependency(t *testing.T) {
+	setup := helper("exit")
+	setup.Kind = shell.KindOneshot
+	driver := helper("run")
+	driver.DependsOn = []string{"setup", "reindex"}
+	reindex := helper("exit")
+	reindex.Schedule = "@every 1h"
+	s := NewSupervisor(&shell.Configuration{Services: map[string]shell.Service{
+		"setup":   setup,
+		"reindex": reindex,
+		"driver":  driver,
+	}})
+	// The driver starts once setup succeeds, long before the timeout, and does not wait for the scheduled service
+	s.ReadyTimeout = time.Minute
+	if err := s.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.StopAll)
+
+	waitForService(t, s, "driver", shell.StateRunning)
+	if status, _ := s.Status("setup"); status.State != shell.StateSucceeded {
+		t.Fatalf("Expected setup to succeed, got %+v", status)
+	}
+	if status, _ := s.Status("reindex"); status.State != shell.StateScheduled || status.StartCount != 0 {
+		t.Fatalf("Expected reindex to wait for its schedule, got %+v", status)
+	}
+	if err := s.RunNow("reindex"); err != nil {
+		t.Fatal(err)
+	}
+	waitForService(t, s, "reindex", shell.StateScheduled)
+	if status, _ := s.Status("reindex"); status.StartCount != 1 {
+		t.Fatalf("Expected reindex to run once, got %+v", status)
+	}
+}
+
+func TestSupervisorScheduledDependency(t *testing.T) {
+	reindex := helper("exit")
+	reindex.Schedule = "@every 20ms"
+	reindex.DependsOn = []string{"driver"}
+	s := NewSupervisor(&shell.Configuration{Services: map[string]shell.Service{
+		"driver":  helper("run"),
+		"reindex": reindex,
+	}})
+	s.ReadyTimeout = time.Minute
+	if err := s.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.StopAll)
+
+	waitForService(t, s, "driver", shell.StateRunning)
+	time.Sleep(time.Millisecond * 100)
+	if status, _ := s.Status("reindex"); status.StartCount != 0 {
+		t.Fatalf("Expected reindex to wait for driver, got %+v", status)
+	}
+	s.Ready("driver")
+	deadline := time.Now().Add(5 * time.Second)
+	for status, _ := s.Status("reindex"); status.StartCount == 0; status, _ = s.Status("reindex") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected reindex to run once driver is ready, got %+v", status)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestSupervisorUpdate(t *testing.T) {
+	s := supervise(t, map[string]shell.Service{
+		"events": helper("run"),
+		"driver": helper("run"),
+		"toast":  helper("run"),
+	})
+	events := waitForService(t, s, "events", shell.StateRunning)
+	driver := waitForService(t, s, "driver", shell.StateRunning)
+	waitForService(t, s, "toast", shell.StateRunning)
+
+	diff, err := s.Update(func(current *shell.Configuration) (*shell.Configuration, error) {
+		next, _ := current.WithoutService("toast")
+		changed := helper("run")
+		changed.Arguments = []string{"-test.run=none"}
+		next.Services["driver"] = changed
+		next.Services["hotkeys"] = helper("run")
+		return next, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Changed) != 1 {
+		t.Fatalf("Expected one added, removed and changed service, got %+v", diff)
+	}
+
+	waitForService(t, s, "hotkeys", shell.StateRunning)
+	if status := waitForService(t, s, "driver", shell.StateRunning); status.Pid == driver.Pid || status.StartCount != 2 {
+		t.Fatalf("Expected driver to be restarted, got %+v", status)
+	}
+	if status, _ := s.Status("events"); status.Pid != events.Pid {
+		t.Fatalf("Expected events to keep running, got %+v", status)
+	}
+	if _, err := s.Status("toast"); err == nil {
+		t.Fatalf("Expected toast to be removed")
+	}
+	if list := s.List(); len(list) != 3 || list[0].Name != "driver" {
+		t.Fatalf("Expected driver, events and hotkeys, got %+v", list)
+	}
+}
+
+func TestConcurrentSupervisorOperations(t *testing.T) {
+	s := supervise(t, map[string]shell.Service{
+		"events": helper("run"),
+		"driver": helper("run"),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < 10; k++ {
+				switch (i + k) % 5 {
+				case 0:
+					s.Restart("driver")
+				case 1:
+					s.Stop("events")
+				case 2:
+					s.Start("events")
+				case 3:
+					s.List()
+					s.Config()
+				case 4:
+					s.Update(func(current *shell.Configuration) (*shell.Configuration, error) {
+						if _, ok := current.Services["toast"]; ok {
+							return current.WithoutService("toast")
+						}
+						return current.WithService("toast", string(utils.EncodeAny(helper("run"))))
+					})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s.StopAll()
+	for _, status := range s.List() {
+		if status.State == shell.StateRunning || status.State == shell.StateStarting {
+			t.Fatalf("Expected every service to be stopped, got %+v", status)
+		}
+	}
+}
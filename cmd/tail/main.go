@@ -0,0 +1,56 @@
+// Print the output of services recorded by the log service.
+//
+//	tail [-f] [-n lines] [-stream stdout|stderr] [-since duration] [-grep regex] [service]
+//
+// With -f, new output is printed as it is written.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+)
+
+func printEntry(e logs.Entry) {
+	fmt.Printf("%s %s %s: %s\n", e.Time.Format("15:04:05.000"), e.Service, e.Stream, e.Line)
+}
+
+func main() {
+	follow := flag.Bool("f", false, "print new output as it is written")
+	lines := flag.Int("n", 20, "the number of recorded lines to print")
+	stream := flag.String("stream", "", "only print stdout or stderr")
+	since := flag.Duration("since", 0, "only print output written within this duration")
+	grep := flag.String("grep", "", "only print lines matching this regular expression")
+	flag.Parse()
+
+	filter := logs.Filter{Service: flag.Arg(0), Stream: *stream, Regex: *grep, Limit: *lines}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	nc := client.Default()
+	defer nc.Close()
+
+	var err error
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		err = nc.FollowLogs(ctx, filter, printEntry)
+	} else {
+		var entries []logs.Entry
+		entries, err = nc.Request.QueryLogs(context.Background(), filter)
+		for _, e := range entries {
+			printEntry(e)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 
 	// "github.com/go-gl/gltext"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
 	"github.com/operdies/windows-nats-shell/pkg/nats/client"
 )
@@ -65,17 +66,18 @@ func main() {
 	nc.Subscribe.ShellToast(func(t shell.Toast) {
 		fmt.Printf("t: %+v\n", t)
 	})
-	c2, _ := nats.Connect(nats.DefaultURL)
 	messages := make([]string, 0, 10)
 	msgLock := sync.Mutex{}
-	c2.Subscribe("stdout.>", func(msg *nats.Msg) {
+	addMessage := func(msg *nats.Msg) {
 		msgLock.Lock()
 		defer msgLock.Unlock()
 		if len(messages) >= 10 {
 			messages = messages[1:]
 		}
 		messages = append(messages, msg.Subject+" : "+string(msg.Data))
-	})
+	}
+	nc.Conn().Subscribe(logs.Stdout+".>", addMessage)
+	nc.Conn().Subscribe(logs.Stderr+".>", addMessage)
 
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
@@ -0,0 +1,50 @@
+// Print the span tree of a trace recorded by the shell.
+//
+//	trace [trace id]
+//
+// Without a trace id, the most recent trace is printed.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/client"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
+)
+
+func printNode(node *trace.Node, depth int) {
+	outcome := "ok"
+	if node.Code != "" {
+		outcome = node.Message
+	}
+	fmt.Printf("%s%s %s (%s) %v %s\n", strings.Repeat("  ", depth), node.Service, node.Subject, node.Id, node.Duration, outcome)
+	for _, child := range node.Children {
+		printNode(child, depth+1)
+	}
+}
+
+func main() {
+	var id string
+	if len(os.Args) > 1 {
+		id = os.Args[1]
+	}
+	nc := client.Default()
+	defer nc.Close()
+
+	spans, err := nc.Request.Trace(context.Background(), id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(spans) == 0 {
+		fmt.Fprintln(os.Stderr, "No spans were recorded. Is tracing enabled in the nats section of the config?")
+		os.Exit(1)
+	}
+	fmt.Printf("Trace %s\n", spans[0].Trace)
+	for _, root := range trace.Tree(spans) {
+		printNode(root, 1)
+	}
+}
@@ -1,6 +1,7 @@
 package inputhandler
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/operdies/windows-nats-shell/cmd/windowmanager/windowmanager"
@@ -14,6 +15,8 @@ import (
 )
 
 type InputHandler struct {
+	// Windows are only focused and moved until ctx is done
+	ctx       context.Context
 	keyMods   map[input.VKEY]bool
 	eventInfo eventInfo
 	wm        *windowmanager.WindowManager
@@ -36,8 +39,9 @@ type eventInfo struct {
 	startPosition windows.Rect
 }
 
-func Create(wm *windowmanager.WindowManager) *InputHandler {
+func Create(ctx context.Context, wm *windowmanager.WindowManager) *InputHandler {
 	var handler InputHandler
+	handler.ctx = ctx
 	handler.keyMods = map[input.VKEY]bool{}
 	handler.wm = wm
 
@@ -61,9 +65,9 @@ func (h *InputHandler) OnKeyboardInput(kei keyboard.KeyboardEventInfo) bool {
 
 	if keyDown && vkey == h.wm.Config.CycleVKey && h.actionKeyDown() {
 		if h.isKeyDown(input.VK_LSHIFT) {
-			h.wm.FocusPrevWindow()
+			h.wm.FocusPrevWindow(h.ctx)
 		} else {
-			h.wm.FocusNextWindow()
+			h.wm.FocusNextWindow(h.ctx)
 		}
 		return true
 	}
@@ -114,6 +118,9 @@ func (h *InputHandler) resizeStart(mei mouse.MouseEventInfo) {
 	}
 }
 func applyResize(h *InputHandler, p windows.Point) {
+	if h.ctx.Err() != nil {
+		return
+	}
 	delta := h.eventInfo.trigger.Point.Sub(p)
 	d := h.eventInfo.resizeDirection
 	r := h.eventInfo.startPosition
@@ -158,6 +165,9 @@ func (h *InputHandler) dragStart(mei mouse.MouseEventInfo) {
 }
 
 func applyMove(h *InputHandler, p windows.Point) {
+	if h.ctx.Err() != nil {
+		return
+	}
 	// delta := h.resizeEventInfo.trigger.Point.Sub(p)
 	// r := h.resizeEventInfo.startPosition.Transform(-int32(delta.X), -int32(delta.Y))
 	// winapiabstractions.SetWindowRect(h.resizeEventInfo.handle, r)
@@ -175,7 +185,7 @@ func (h *InputHandler) dragging(mei mouse.MouseEventInfo) {
 }
 func (h *InputHandler) dragEnd(mei mouse.MouseEventInfo) {
 	if h.eventInfo.dragged == false {
-		h.wm.FocusThisWindow(getRootOwnerAtPoint(mei))
+		h.wm.FocusThisWindow(h.ctx, getRootOwnerAtPoint(mei))
 	} else {
 		h.dragging(mei)
 	}
@@ -222,9 +232,9 @@ func (h *InputHandler) OnMouseInput(mei mouse.MouseEventInfo) bool {
 	case mouse.VMOUSEWHEEL:
 		if h.actionKeyDown() {
 			if mei.WheelDelta > 0 {
-				go h.wm.FocusNextWindow()
+				go h.wm.FocusNextWindow(h.ctx)
 			} else {
-				go h.wm.FocusPrevWindow()
+				go h.wm.FocusPrevWindow(h.ctx)
 			}
 			return true
 		}
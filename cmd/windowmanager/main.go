@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/operdies/windows-nats-shell/cmd/windowmanager/inputhandler"
 	"github.com/operdies/windows-nats-shell/cmd/windowmanager/windowmanager"
 	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
@@ -9,15 +11,21 @@ import (
 )
 
 func main() {
+	ctx, cancel := context.WithCancel(context.Background())
 	nc := client.Default()
-	cfg := client.GetConfig[windowmanager.Config](nc.Request)
+	cfg, err := client.GetConfig[windowmanager.Config](ctx, nc.Request)
+	if err != nil {
+		panic(err)
+	}
 	wm := windowmanager.Create(cfg)
 
-	inputHandler := inputhandler.Create(wm)
+	inputHandler := inputhandler.Create(ctx, wm)
 	mouseHook, _ := mouse.InstallHook(inputHandler.OnMouseInput)
-	defer mouseHook.Uninstall()
+	nc.OnShutdown(func() { mouseHook.Uninstall() })
 	keyHook, _ := keyboard.InstallHook(inputHandler.OnKeyboardInput)
-	defer keyHook.Uninstall()
+	nc.OnShutdown(func() { keyHook.Uninstall() })
+	// Stop focusing and moving windows before the hooks are uninstalled
+	nc.OnShutdown(cancel)
 
-	select {}
+	nc.Run(ctx)
 }
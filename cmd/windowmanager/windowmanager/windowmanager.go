@@ -130,14 +130,15 @@ func Create(cfg Config) *WindowManager {
 	return &man
 }
 
-func (wm *WindowManager) cancelAndCreateContext() context.Context {
+// Cancel the running animation, and create a context for the next one which ends with ctx
+func (wm *WindowManager) cancelAndCreateContext(ctx context.Context) context.Context {
 	wm.cancelContextLock.Lock()
 	defer wm.cancelContextLock.Unlock()
 	// Cancel any currently running animation
 	if wm.cancelLayoutChange != nil {
 		wm.cancelLayoutChange()
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(wm.Config.AnimationTime))
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*time.Duration(wm.Config.AnimationTime))
 	wm.cancelLayoutChange = cancel
 	return ctx
 }
@@ -155,11 +156,11 @@ func reallyFocus(h wintypes.HWND, tries int, ctx context.Context) {
 		time.Sleep(time.Millisecond)
 	}
 }
-func (wm *WindowManager) cycleWindows(reverse bool) {
+func (wm *WindowManager) cycleWindows(ctx context.Context, reverse bool) {
 	wm.windowListLock.Lock()
 	defer wm.windowListLock.Unlock()
 	wm.updateWindowList()
-	ctx := wm.cancelAndCreateContext()
+	ctx = wm.cancelAndCreateContext(ctx)
 	if len(wm.windowList) == 0 {
 		return
 	}
@@ -177,7 +178,7 @@ func (wm *WindowManager) cycleWindows(reverse bool) {
 	reallyFocus(wm.windowList[0], 50, ctx)
 }
 
-func (wm *WindowManager) swapWindows(a, b wintypes.HWND) {
+func (wm *WindowManager) swapWindows(ctx context.Context, a, b wintypes.HWND) {
 	if a == 0 || b == 0 {
 		return
 	}
@@ -199,7 +200,7 @@ func (wm *WindowManager) swapWindows(a, b wintypes.HWND) {
 		}
 	}
 
-	ctx := wm.cancelAndCreateContext()
+	ctx = wm.cancelAndCreateContext(ctx)
 	go reallyFocus(a, 50, ctx)
 	wm.windowList[aIdx], wm.windowList[bIdx] = wm.windowList[bIdx], wm.windowList[aIdx]
 	r1 := winapi.GetWindowRect(a)
@@ -264,10 +265,10 @@ func (wm *WindowManager) fixZOrder() {
 	}
 }
 
-func (wm *WindowManager) refresh() {
+func (wm *WindowManager) refresh(ctx context.Context) {
 	wm.windowListLock.Lock()
 	defer wm.windowListLock.Unlock()
-	ctx := wm.cancelAndCreateContext()
+	ctx = wm.cancelAndCreateContext(ctx)
 	wm.updateWindowList()
 	wm.fixZOrder()
 	wm.calculateLayout(ctx)
@@ -297,13 +298,13 @@ func (wm *WindowManager) calculateLayout(ctx context.Context) {
 	}
 }
 
-func (wm *WindowManager) FocusPrevWindow() {
-	wm.cycleWindows(true)
+func (wm *WindowManager) FocusPrevWindow(ctx context.Context) {
+	wm.cycleWindows(ctx, true)
 }
-func (wm *WindowManager) FocusNextWindow() {
-	wm.cycleWindows(false)
+func (wm *WindowManager) FocusNextWindow(ctx context.Context) {
+	wm.cycleWindows(ctx, false)
 }
-func (wm *WindowManager) FocusThisWindow(next wintypes.HWND) {
+func (wm *WindowManager) FocusThisWindow(ctx context.Context, next wintypes.HWND) {
 	current := winapi.GetForegroundWindow()
-	wm.swapWindows(next, current)
+	wm.swapWindows(ctx, next, current)
 }
@@ -4,27 +4,27 @@ go 1.19
 
 require (
 	github.com/4ydx/gltext v0.0.0-20181021030543-84bc6aa204bf
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/go-gl/gl v0.0.0-20211210172815-726fda9656d6
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20220806181222-55e207c401ad
-	github.com/nats-io/nats.go v1.17.0
-	golang.org/x/sys v0.0.0-20221013171732-95e765b1cc43
-	gopkg.in/yaml.v3 v3.0.1
-)
-
-require (
 	github.com/go-gl/mathgl v1.0.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/image v0.1.0
-	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
-)
-
-require (
-	github.com/fsnotify/fsnotify v1.5.4
 	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/klauspost/compress v1.15.10 // indirect
+	github.com/minio/highwayhash v1.0.2 // indirect
 	github.com/natefinch/npipe v0.0.0-20160621034901-c1b8fa8bdcce
-	github.com/nats-io/nats-server/v2 v2.9.1 // indirect
+	github.com/nats-io/jwt/v2 v2.3.0 // indirect
+	github.com/nats-io/nats-server/v2 v2.9.1
+	github.com/nats-io/nats.go v1.17.0
 	github.com/nats-io/nkeys v0.3.0 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/crypto v0.0.0-20220919173607-35f4265a4bc0 // indirect
+	golang.org/x/image v0.1.0
+	golang.org/x/sys v0.0.0-20221013171732-95e765b1cc43
+	golang.org/x/time v0.0.0-20220920022843-2ce7c2934d45 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
+	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
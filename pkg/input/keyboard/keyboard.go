@@ -1,3 +1,5 @@
+//go:build windows
+
 package keyboard
 
 import (
@@ -10,20 +12,6 @@ import (
 	"github.com/operdies/windows-nats-shell/pkg/wintypes"
 )
 
-type KeyboardEventInfo struct {
-	ScanCode uint64
-	// The scan code. The value depends on the OEM.
-	VirtualKeyCode uint64
-	// Indicates whether the key is an extended key, such as a function key or a key on the numeric keypad. The value is 1 if the key is an extended key; otherwise, it is 0.
-	IsExtended bool
-	// True if ALT is down, otherwise 0
-	ContextCode bool
-	// The transition state. The value is 0 if the key is being pressed and 1 if it is being released.
-	TransitionState bool
-	// The time stamp for this message
-	Time wintypes.DWORD
-}
-
 type _KBDLLHOOKSTRUCT struct {
 	VkCode      wintypes.DWORD
 	ScanCode    wintypes.DWORD
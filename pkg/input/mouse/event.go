@@ -0,0 +1,29 @@
+package mouse
+
+import (
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/windows"
+	"github.com/operdies/windows-nats-shell/pkg/wintypes"
+)
+
+type MouseAction = uint32
+
+const (
+	MOUSEMOVE   MouseAction = 0x200
+	LBUTTONDOWN MouseAction = 0x201
+	LBUTTONUP   MouseAction = 0x202
+	RBUTTONDOWN MouseAction = 0x204
+	RBUTTONUP   MouseAction = 0x205
+	VMOUSEWHEEL MouseAction = 0x20A
+	HMOUSEWHEEL MouseAction = 0x20E
+)
+
+type MouseEventInfo struct {
+	// The mouse action being triggered
+	Action MouseAction
+	// The point in per-monitor aware screen coordinates the action was triggered
+	Point windows.Point
+	// A positive value indicates that the wheel was rotated forward, away from the user; a negative value indicates that the wheel was rotated backward, toward the user. One wheel click is defined as WHEEL_DELTA, which is 120.
+	WheelDelta int16
+	// The time stamp for this message.
+	Time wintypes.DWORD
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package mouse
 
 import (
@@ -19,29 +21,6 @@ type _MSLLHOOKSTRUCT struct {
 	dwExtraInfo wintypes.LONG_PTR
 }
 
-type MouseAction = uint32
-
-const (
-	MOUSEMOVE   MouseAction = 0x200
-	LBUTTONDOWN MouseAction = 0x201
-	LBUTTONUP   MouseAction = 0x202
-	RBUTTONDOWN MouseAction = 0x204
-	RBUTTONUP   MouseAction = 0x205
-	VMOUSEWHEEL MouseAction = 0x20A
-	HMOUSEWHEEL MouseAction = 0x20E
-)
-
-type MouseEventInfo struct {
-	// The mouse action being triggered
-	Action MouseAction
-	// The point in per-monitor aware screen coordinates the action was triggered
-	Point windows.Point
-	// A positive value indicates that the wheel was rotated forward, away from the user; a negative value indicates that the wheel was rotated backward, toward the user. One wheel click is defined as WHEEL_DELTA, which is 120.
-	WheelDelta int16
-	// The time stamp for this message.
-	Time wintypes.DWORD
-}
-
 func Words(dword wintypes.DWORD) (lower, higher int16) {
 	return int16(dword & 0xFF),
 		int16(dword >> 16)
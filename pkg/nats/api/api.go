@@ -0,0 +1,16 @@
+package api
+
+// Endpoint describes a request/reply subject. Requests carry a Req payload,
+// and subscribers reply with a Resp wrapped in a reply.Envelope.
+type Endpoint[Req, Resp any] struct {
+	Subject string
+}
+
+// Event describes a subject which carries T payloads and expects no reply
+type Event[T any] struct {
+	Subject string
+}
+
+// The payload of requests which take no arguments,
+// and the result of requests which only report errors
+type Empty = struct{}
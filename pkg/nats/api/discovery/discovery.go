@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+)
+
+const (
+	// Get every running service and the subjects it handles
+	Discover = "Shell.Discover"
+	// Every client answers with a description of itself
+	Describe = "Discovery.Describe"
+)
+
+var (
+	DiscoverEndpoint = api.Endpoint[api.Empty, []Service]{Subject: Discover}
+	DescribeEndpoint = api.Endpoint[api.Empty, Service]{Subject: Describe}
+)
+
+type Kind = string
+
+const (
+	// The subject answers requests
+	Request Kind = "request"
+	// The subject receives events
+	Event Kind = "event"
+)
+
+// A subject handled by a service
+type Subject struct {
+	Subject string
+	Kind    Kind
+	// The payload of requests or events
+	Request *Schema
+	// The result of requests
+	Response *Schema
+}
+
+// A running client and the subjects it handles
+type Service struct {
+	// The name of the connection
+	Name string
+	// The namespace the client answers instance requests in
+	Instance string
+	Version  string
+	Subjects []Subject
+}
+
+// Check if any service handles a subject
+func Handled(services []Service, subject string) bool {
+	for _, s := range services {
+		for _, sub := range s.Subjects {
+			if sub.Subject == subject {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Schema describes the shape of a payload
+type Schema struct {
+	// One of object, array, map, string, integer, number, boolean or any
+	Type string
+	// The name of the Go type, if it is named
+	Name string `yaml:",omitempty" json:",omitempty" msgpack:",omitempty"`
+	// The fields of an object
+	Fields []Field `yaml:",omitempty" json:",omitempty" msgpack:",omitempty"`
+	// The elements of an array, or the values of a map
+	Items *Schema `yaml:",omitempty" json:",omitempty" msgpack:",omitempty"`
+}
+
+type Field struct {
+	Name   string
+	Schema *Schema
+}
+
+// Describe the payload of type T
+func SchemaOf[T any]() *Schema {
+	return schemaOf(reflect.TypeOf((*T)(nil)).Elem(), map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := &Schema{Name: t.Name()}
+	if t.PkgPath() != "" {
+		s.Name = t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.Type = "integer"
+	case reflect.Float32, reflect.Float64:
+		s.Type = "number"
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Slice, reflect.Array:
+		s.Type = "array"
+		s.Items = schemaOf(t.Elem(), seen)
+	case reflect.Map:
+		s.Type = "map"
+		s.Items = schemaOf(t.Elem(), seen)
+	case reflect.Struct:
+		s.Type = "object"
+		// Recursive types are only described once
+		if seen[t] {
+			return s
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() {
+				s.Fields = append(s.Fields, Field{Name: f.Name, Schema: schemaOf(f.Type, seen)})
+			}
+		}
+	default:
+		s.Type = "any"
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"testing"
+)
+
+type node struct {
+	Value    int
+	Children []*node
+	Tags     map[string]string
+	hidden   bool
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf[node]()
+	if s.Type != "object" || s.Name != "discovery.node" || len(s.Fields) != 3 {
+		t.Fatalf("Expected an object with 3 fields, got %+v", s)
+	}
+	children := s.Fields[1].Schema
+	if children.Type != "array" || children.Items.Name != "discovery.node" || children.Items.Fields != nil {
+		t.Fatalf("Expected a recursive reference without fields, got %+v", children.Items)
+	}
+	if tags := s.Fields[2].Schema; tags.Type != "map" || tags.Items.Type != "string" {
+		t.Fatalf("Expected a map of strings, got %+v", tags)
+	}
+	if s := SchemaOf[any](); s.Type != "any" {
+		t.Fatalf("Expected any, got %+v", s)
+	}
+}
@@ -0,0 +1,12 @@
+package health
+
+import "github.com/operdies/windows-nats-shell/pkg/nats/api"
+
+const (
+	// Every client answers pings with its name. Ping '<service>.Health.Ping' to check a specific service.
+	Ping = "Health.Ping"
+)
+
+var (
+	PingEndpoint = api.Endpoint[api.Empty, string]{Subject: Ping}
+)
@@ -0,0 +1,92 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+)
+
+const (
+	// Every line a service writes to stdout is published to 'stdout.<service>'
+	Stdout = "stdout"
+	// Every line a service writes to stderr is published to 'stderr.<service>'
+	Stderr = "stderr"
+	// Get recorded lines from the log service
+	Query = "Logs.Query"
+)
+
+const (
+	// When the line was written, in RFC3339 format with nanoseconds
+	TimestampHeader = "Timestamp"
+	// Counts the lines of a stream, starting at 1
+	SequenceHeader = "Sequence"
+	// Set if the line was longer than MaxLineLength, and the rest of it was discarded
+	TruncatedHeader = "Truncated"
+)
+
+// Longer lines are truncated, so a runaway service can not flood the bus
+const MaxLineLength = 4096
+
+// Get the subject a stream of a service is published to
+func Subject(stream, service string) string {
+	return stream + "." + service
+}
+
+var (
+	QueryEndpoint = api.Endpoint[Filter, []Entry]{Subject: Query}
+)
+
+// A line written by a service
+type Entry struct {
+	Service string
+	// Stdout or Stderr
+	Stream    string
+	Time      time.Time
+	Sequence  int
+	Truncated bool
+	Line      string
+}
+
+// Selects entries. Empty fields match every entry.
+type Filter struct {
+	Service string
+	// Stdout or Stderr
+	Stream string
+	// Only entries written at or after Since, and before Until
+	Since time.Time
+	Until time.Time
+	// Only lines containing this string
+	Contains string
+	// Only lines matching this regular expression
+	Regex string
+	// The maximum number of entries. The most recent entries are returned. Defaults to 100.
+	Limit int
+}
+
+const DefaultLimit = 100
+
+// Compile the filter to a function which checks if an entry is selected
+func (f Filter) Matcher() (func(Entry) bool, error) {
+	var re *regexp.Regexp
+	if f.Regex != "" {
+		var err error
+		if re, err = regexp.Compile(f.Regex); err != nil {
+			return nil, err
+		}
+	}
+	return func(e Entry) bool {
+		switch {
+		case f.Service != "" && e.Service != f.Service:
+		case f.Stream != "" && e.Stream != f.Stream:
+		case !f.Since.IsZero() && e.Time.Before(f.Since):
+		case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		case f.Contains != "" && !strings.Contains(e.Line, f.Contains):
+		case re != nil && !re.MatchString(e.Line):
+		default:
+			return true
+		}
+		return false
+	}, nil
+}
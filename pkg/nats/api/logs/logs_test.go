@@ -0,0 +1,39 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatcher(t *testing.T) {
+	now := time.Now()
+	entry := Entry{Service: "driver", Stream: Stderr, Time: now, Line: "Process hotkeys exited. (2)"}
+	cases := []struct {
+		filter   Filter
+		expected bool
+	}{
+		{Filter{}, true},
+		{Filter{Service: "driver", Stream: Stderr}, true},
+		{Filter{Service: "hotkeys"}, false},
+		{Filter{Stream: Stdout}, false},
+		{Filter{Since: now, Until: now.Add(time.Second)}, true},
+		{Filter{Since: now.Add(time.Nanosecond)}, false},
+		{Filter{Until: now}, false},
+		{Filter{Contains: "hotkeys"}, true},
+		{Filter{Contains: "Hotkeys"}, false},
+		{Filter{Regex: `exited\. \([1-9]\)$`}, true},
+		{Filter{Regex: `^exited`}, false},
+	}
+	for i, c := range cases {
+		match, err := c.filter.Matcher()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match(entry) != c.expected {
+			t.Fatalf("Case %d: expected %v for %+v", i, c.expected, c.filter)
+		}
+	}
+	if _, err := (Filter{Regex: "("}).Matcher(); err == nil {
+		t.Fatalf("Expected an invalid regex to fail")
+	}
+}
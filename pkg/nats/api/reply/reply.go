@@ -0,0 +1,101 @@
+package reply
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A machine readable classification of a failed request
+type ErrorCode = string
+
+const (
+	// The request succeeded
+	Ok ErrorCode = ""
+	// The request referred to something that is not configured, e.g. an unknown service
+	NotConfigured ErrorCode = "NotConfigured"
+	// The request referred to something that does not exist, e.g. a missing program
+	NotFound ErrorCode = "NotFound"
+	// The request payload was missing or malformed
+	InvalidRequest ErrorCode = "InvalidRequest"
+	// No reply was received before the deadline
+	Timeout ErrorCode = "Timeout"
+	// The requester gave up on the request before a reply was received
+	Canceled ErrorCode = "Canceled"
+	// The subscriber refused to handle the request
+	Unauthorized ErrorCode = "Unauthorized"
+	// Nobody is subscribed to the subject
+	NoResponders ErrorCode = "NoResponders"
+	// The request was received, but could not be completed
+	Failed ErrorCode = "Failed"
+)
+
+// Error is the error type carried by an Envelope.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func Errorf(code ErrorCode, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Get the error code of an error. Errors which do not originate from
+// an Envelope are classified as Failed.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return Ok
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Failed
+}
+
+func IsCode(err error, code ErrorCode) bool {
+	return err != nil && CodeOf(err) == code
+}
+
+// Envelope is the reply sent by every subscriber which answers a request.
+// Code is empty if the request succeeded, in which case Result is valid.
+type Envelope[T any] struct {
+	Result  T
+	Code    ErrorCode
+	Message string
+}
+
+func Success[T any](result T) Envelope[T] {
+	return Envelope[T]{Result: result}
+}
+
+func Fail[T any](err error) Envelope[T] {
+	var e *Error
+	if errors.As(err, &e) {
+		return Envelope[T]{Code: e.Code, Message: e.Message}
+	}
+	return Envelope[T]{Code: Failed, Message: err.Error()}
+}
+
+// Create a successful envelope if err is nil, and a failed envelope otherwise
+func From[T any](result T, err error) Envelope[T] {
+	if err != nil {
+		return Fail[T](err)
+	}
+	return Success(result)
+}
+
+// Get the result, or the error if the request failed
+func (e Envelope[T]) Unwrap() (T, error) {
+	if e.Code != Ok {
+		var zero T
+		return zero, &Error{Code: e.Code, Message: e.Message}
+	}
+	return e.Result, nil
+}
@@ -0,0 +1,52 @@
+package reply
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/operdies/windows-nats-shell/pkg/utils"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	{
+		env := utils.DecodeAny[Envelope[[]string]](utils.EncodeAny(Success([]string{"a", "b"})))
+		result, err := env.Unwrap()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(result) != 2 || result[0] != "a" || result[1] != "b" {
+			t.Fatalf("Unexpected result %v", result)
+		}
+	}
+
+	{
+		sent := Fail[bool](Errorf(NotConfigured, "Service '%s' is not configured.", "driver"))
+		env := utils.DecodeAny[Envelope[bool]](utils.EncodeAny(sent))
+		_, err := env.Unwrap()
+		if !IsCode(err, NotConfigured) {
+			t.Fatalf("Expected %s, got %v", NotConfigured, err)
+		}
+		if err.Error() != "NotConfigured: Service 'driver' is not configured." {
+			t.Fatalf("Unexpected message: %v", err.Error())
+		}
+	}
+
+	{
+		sent := From(0, errors.New("boom"))
+		_, err := sent.Unwrap()
+		if CodeOf(err) != Failed {
+			t.Fatalf("Expected plain errors to be classified as %s, got %v", Failed, CodeOf(err))
+		}
+	}
+}
+
+func TestCodeOfWrappedError(t *testing.T) {
+	err := fmt.Errorf("while starting: %w", Errorf(Timeout, "no reply"))
+	if CodeOf(err) != Timeout {
+		t.Fatalf("Expected %s, got %s", Timeout, CodeOf(err))
+	}
+	if CodeOf(nil) != Ok {
+		t.Fatalf("Expected nil to be Ok")
+	}
+}
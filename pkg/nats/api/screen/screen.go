@@ -1,5 +1,7 @@
 package screen
 
+import "github.com/operdies/windows-nats-shell/pkg/nats/api"
+
 const (
 	// Get the current resolution
 	GetResolution = "Screen.GetResolution"
@@ -7,6 +9,11 @@ const (
 	SetResolution = "Screen.SetResolution"
 )
 
+var (
+	GetResolutionEndpoint = api.Endpoint[api.Empty, Resolution]{Subject: GetResolution}
+	SetResolutionEndpoint = api.Endpoint[Resolution, api.Empty]{Subject: SetResolution}
+)
+
 type Resolution struct {
 	Width  uint32
 	Height uint32
@@ -0,0 +1,86 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Get the order services should be started in, such that every service is started after
+// the services it depends on. Services are stopped in the reverse order.
+// Unknown dependencies and circular dependencies are errors.
+func (c *Configuration) StartOrder() ([]string, error) {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	// Visit services in a fixed order so the result does not depend on map iteration
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var order []string
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(path[indexOf(path, name):], name)
+			return fmt.Errorf("Circular dependency: %s", strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range c.Services[name].DependsOn {
+			if _, ok := c.Services[dep]; !ok {
+				return fmt.Errorf("Service '%s' depends on '%s', which is not configured.", name, dep)
+			}
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Get the services which depend on a service, directly or indirectly
+func (c *Configuration) Dependents(service string) []string {
+	var result []string
+	seen := map[string]bool{service: true}
+	queue := []string{service}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for name, s := range c.Services {
+			if seen[name] || indexOf(s.DependsOn, current) < 0 {
+				continue
+			}
+			seen[name] = true
+			result = append(result, name)
+			queue = append(queue, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
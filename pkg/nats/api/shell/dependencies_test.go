@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func services(deps map[string][]string) *Configuration {
+	cfg := &Configuration{Services: map[string]Service{}}
+	for name, d := range deps {
+		cfg.Services[name] = Service{DependsOn: d}
+	}
+	return cfg
+}
+
+func TestStartOrder(t *testing.T) {
+	cfg := services(map[string][]string{
+		"windowmanager": {"driver"},
+		"hotkeys":       {"driver", "windowmanager"},
+		"driver":        {"events"},
+		"events":        nil,
+		"toast":         nil,
+	})
+	order, err := cfg.StartOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := map[string]int{}
+	for i, name := range order {
+		index[name] = i
+	}
+	if len(order) != 5 {
+		t.Fatalf("Expected 5 services, got %v", order)
+	}
+	for name, s := range cfg.Services {
+		for _, dep := range s.DependsOn {
+			if index[dep] > index[name] {
+				t.Fatalf("Expected %s to start before %s, got %v", dep, name, order)
+			}
+		}
+	}
+
+	dependents := strings.Join(cfg.Dependents("driver"), ",")
+	if dependents != "hotkeys,windowmanager" {
+		t.Fatalf("Expected hotkeys and windowmanager to depend on driver, got %s", dependents)
+	}
+}
+
+func TestDependencyErrors(t *testing.T) {
+	_, err := services(map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+	}).StartOrder()
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Fatalf("Expected a circular dependency, got %v", err)
+	}
+
+	_, err = services(map[string][]string{"a": {"missing"}}).StartOrder()
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("Expected an unknown dependency, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package shell
+
+import (
+	"reflect"
+	"sort"
+)
+
+// The services affected by a config change
+type ConfigDiff struct {
+	// Services which are only in the new config
+	Added []string
+	// Services which are only in the old config
+	Removed []string
+	// Services whose entry or service config section changed
+	Changed []string
+	// Set if the NATS config changed, in which case every service must be restarted
+	Nats bool
+}
+
+// Check if the configs are equivalent
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && !d.Nats
+}
+
+// Compare the config to a newer config
+func (c *Configuration) Diff(next *Configuration) ConfigDiff {
+	var diff ConfigDiff
+	diff.Nats = !reflect.DeepEqual(c.Nats, next.Nats)
+	for name, s := range next.Services {
+		old, ok := c.Services[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, name)
+		case !reflect.DeepEqual(old, s) || !reflect.DeepEqual(c.ServiceConfigs[name], next.ServiceConfigs[name]):
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range c.Services {
+		if _, ok := next.Services[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
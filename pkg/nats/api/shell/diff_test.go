@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := &Configuration{
+		Services: map[string]Service{
+			"driver":  {Executable: "driver.exe"},
+			"hotkeys": {Executable: "hotkeys.exe"},
+			"toast":   {Executable: "toast.exe"},
+			"events":  {Executable: "events.exe"},
+		},
+		ServiceConfigs: map[string]any{
+			"hotkeys": map[string]any{"keymap": []any{"ctrl+a"}},
+		},
+	}
+	next := &Configuration{
+		Services: map[string]Service{
+			"driver":        {Executable: "driver.exe", DependsOn: []string{"events"}},
+			"hotkeys":       {Executable: "hotkeys.exe"},
+			"events":        {Executable: "events.exe"},
+			"windowmanager": {Executable: "windowmanager.exe"},
+		},
+		ServiceConfigs: map[string]any{
+			"hotkeys": map[string]any{"keymap": []any{"ctrl+b"}},
+		},
+	}
+
+	diff := old.Diff(next)
+	expected := ConfigDiff{
+		Added:   []string{"windowmanager"},
+		Removed: []string{"toast"},
+		Changed: []string{"driver", "hotkeys"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, diff)
+	}
+
+	if diff := next.Diff(next); !diff.Empty() {
+		t.Fatalf("Expected no changes, got %+v", diff)
+	}
+
+	next.Nats.Url = "nats://127.0.0.1:4223"
+	if diff := old.Diff(next); !diff.Nats {
+		t.Fatalf("Expected the NATS config to change")
+	}
+}
@@ -0,0 +1,236 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type AddServiceRequest struct {
+	Name string
+	// The service as YAML, as it would appear under 'services' in the config file.
+	// This includes the service specific config section.
+	Definition string
+	// Also write the service to the config file. Services which are not
+	// persisted are removed when the config file is reloaded.
+	Persist bool
+}
+
+type RemoveServiceRequest struct {
+	Name string
+	// Also remove the service from the config file
+	Persist bool
+}
+
+type SetConfigRequest struct {
+	// The content of a config file
+	Config string
+	// Also overwrite the config file
+	Persist bool
+}
+
+// Get a copy of the config where a service is added, or replaced if it already exists
+func (c *Configuration) WithService(name, definition string) (*Configuration, error) {
+	if name == "" {
+		return nil, fmt.Errorf("A service must have a name.")
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(definition), &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("The definition of service '%s' is not a mapping.", name)
+	}
+	var service Service
+	var section any
+	if err := node.Decode(&service); err != nil {
+		return nil, err
+	}
+	if err := node.Decode(&section); err != nil {
+		return nil, err
+	}
+	if service.Executable == "" {
+		return nil, fmt.Errorf("Service '%s' has no configured executable.", name)
+	}
+	service, err := service.expand(name, c.dir())
+	if err != nil {
+		return nil, err
+	}
+
+	next := c.clone()
+	next.Services[name] = service
+	next.ServiceConfigs[name] = section
+	if err := next.validate(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Get a copy of the config where a service is removed
+func (c *Configuration) WithoutService(name string) (*Configuration, error) {
+	if _, ok := c.Services[name]; !ok {
+		return nil, fmt.Errorf("Service '%s' is not configured.", name)
+	}
+	next := c.clone()
+	delete(next.Services, name)
+	delete(next.ServiceConfigs, name)
+	if _, err := next.StartOrder(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (c *Configuration) clone() *Configuration {
+	next := *c
+	next.Services = make(map[string]Service, len(c.Services))
+	for name, s := range c.Services {
+		next.Services[name] = s
+	}
+	next.ServiceConfigs = make(map[string]any, len(c.ServiceConfigs))
+	for name, s := range c.ServiceConfigs {
+		next.ServiceConfigs[name] = s
+	}
+	return &next
+}
+
+// Write the definition of a service to a config file. An existing definition is replaced in place,
+// and new services are added at the end of the services section. The rest of the file is left as it is.
+func SaveService(path, name, definition string) error {
+	var value yaml.Node
+	if err := yaml.Unmarshal([]byte(definition), &value); err != nil {
+		return err
+	}
+	if len(value.Content) == 0 {
+		return fmt.Errorf("The definition of service '%s' is empty.", name)
+	}
+	key, err := yaml.Marshal(name)
+	if err != nil {
+		return err
+	}
+	return editServices(path, name, func(indent string) []string {
+		entry := []string{indent + strings.TrimSpace(string(key)) + ":"}
+		for _, line := range strings.Split(strings.TrimRight(definition, "\r\n"), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line != "" {
+				line = indent + "  " + line
+			}
+			entry = append(entry, line)
+		}
+		return entry
+	})
+}
+
+// Remove the definition of a service, and the comment directly above it, from a config file.
+// The rest of the file is left as it is.
+func DeleteService(path, name string) error {
+	return editServices(path, name, nil)
+}
+
+// Replace the lines of a service in a config file with the lines rendered at the indentation of the
+// services section, or remove them if render is nil. Only the lines of the service are touched.
+func editServices(path, name string, render func(indent string) []string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a config file.", path)
+	}
+	newline := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	// Replace lines[from:to], where lines are indexed from 0 and nodes count lines from 1
+	splice := func(from, to int, replacement []string) {
+		lines = append(lines[:from], append(replacement, lines[to:]...)...)
+	}
+
+	root := doc.Content[0]
+	i := indexOfKey(root, "services")
+	if i < 0 {
+		if render != nil {
+			end := trimEnd(lines, 0, len(lines))
+			splice(end, end, append([]string{"services:"}, render("  ")...))
+		}
+	} else {
+		key, services := root.Content[i], root.Content[i+1]
+		// The section ends where the next top level key, or the file, begins
+		end := len(lines)
+		if i+2 < len(root.Content) {
+			end = root.Content[i+2].Line - 1
+		}
+		switch {
+		case services.Kind == yaml.ScalarNode && services.Tag == "!!null" && services.Value == "":
+			// An empty 'services:' section
+			if render != nil {
+				splice(key.Line, key.Line, render("  "))
+			}
+		case services.Kind != yaml.MappingNode || services.Style&yaml.FlowStyle != 0:
+			return fmt.Errorf("The services in %s are not a block mapping.", path)
+		default:
+			indent := strings.Repeat(" ", services.Content[0].Column-1)
+			j := indexOfKey(services, name)
+			if j < 0 {
+				if render != nil {
+					end = trimEnd(lines, key.Line, end)
+					splice(end, end, render(indent))
+				}
+				break
+			}
+			if j+2 < len(services.Content) {
+				end = services.Content[j+2].Line - 1
+			}
+			from := services.Content[j].Line - 1
+			end = trimEnd(lines, from+1, end)
+			if render != nil {
+				splice(from, end, render(indent))
+				break
+			}
+			for from > key.Line && isComment(lines[from-1]) {
+				from--
+			}
+			splice(from, end, nil)
+		}
+	}
+
+	result := []byte(strings.Join(lines, newline))
+	var check struct{ Services map[string]yaml.Node }
+	if err := yaml.Unmarshal(result, &check); err != nil {
+		return fmt.Errorf("Editing service '%s' in %s would break the file. %v", name, path, err)
+	}
+	if _, ok := check.Services[name]; ok != (render != nil) {
+		return fmt.Errorf("Editing service '%s' in %s would break the file.", name, path)
+	}
+	return os.WriteFile(path, result, 0644)
+}
+
+// Move the end of a range of lines back past trailing blank lines and comments,
+// which belong to whatever comes next
+func trimEnd(lines []string, start, end int) int {
+	for end > start && (strings.TrimSpace(lines[end-1]) == "" || isComment(lines[end-1])) {
+		end--
+	}
+	return end
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// Get the index of a key in a mapping node, or -1 if it does not exist
+func indexOfKey(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const testConfig = `nats:
+  url: nats://127.0.0.1:4222 # The NATS server
+services:
+  # Handles events
+  events:
+    executable: ./events.exe
+  driver:
+    executable: ./driver.exe
+    dependson: [events]
+`
+
+func TestWithService(t *testing.T) {
+	cfg, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := cfg.WithService("hotkeys", "executable: ./hotkeys.exe\ndependson: [driver]\nkeymap: [ctrl+a]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Services["hotkeys"]; ok {
+		t.Fatalf("Expected the original config to be unchanged")
+	}
+	if next.Services["hotkeys"].Executable != "./hotkeys.exe" {
+		t.Fatalf("Expected hotkeys to be added, got %+v", next.Services["hotkeys"])
+	}
+	if section, ok := next.ServiceConfigs["hotkeys"].(map[string]any); !ok || section["keymap"] == nil {
+		t.Fatalf("Expected the service config section to be added, got %v", next.ServiceConfigs["hotkeys"])
+	}
+
+	for name, definition := range map[string]string{
+		"":          "executable: ./a.exe",
+		"noexe":     "arguments: [a]",
+		"scalar":    "./a.exe",
+		"dangling":  "executable: ./a.exe\ndependson: [missing]",
+		"malformed": "executable: [",
+	} {
+		if _, err := cfg.WithService(name, definition); err == nil {
+			t.Fatalf("Expected an error when adding '%s'", name)
+		}
+	}
+
+	if _, err := cfg.WithoutService("events"); err == nil {
+		t.Fatalf("Expected an error when removing a dependency")
+	}
+	if _, err := cfg.WithoutService("missing"); err == nil {
+		t.Fatalf("Expected an error when removing a missing service")
+	}
+	next, err = cfg.WithoutService("driver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := next.Services["driver"]; ok {
+		t.Fatalf("Expected driver to be removed")
+	}
+}
+
+func TestSaveService(t *testing.T) {
+	file := path.Join(t.TempDir(), "config.yml")
+	os.WriteFile(file, []byte(testConfig), 0644)
+
+	if err := SaveService(file, "hotkeys", "executable: ./hotkeys.exe # Bindings\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveService(file, "events", "executable: ./events2.exe\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteService(file, "driver"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(file)
+	text := string(content)
+	for _, expected := range []string{"# The NATS server", "# Handles events", "# Bindings", "./events2.exe"} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("Expected '%s' in:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "driver") {
+		t.Fatalf("Expected driver to be removed:\n%s", text)
+	}
+	if strings.Index(text, "events") > strings.Index(text, "hotkeys") {
+		t.Fatalf("Expected the order of services to be preserved:\n%s", text)
+	}
+
+	cfg, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Services) != 2 || cfg.Services["hotkeys"].Executable != "./hotkeys.exe" {
+		t.Fatalf("Expected events and hotkeys, got %+v", cfg.Services)
+	}
+}
+
+func TestSaveServicePreservesFormatting(t *testing.T) {
+	const original = `# Shell config
+
+nats:
+    url:   "nats://127.0.0.1:4222"
+
+services:
+    # Handles events
+    events:
+        executable: './events.exe'
+        shellevents: true
+
+    # Talks to windows
+    driver:
+        executable: ./driver.exe   # The driver
+        dependson: [ events ]
+
+# Watch for changes
+watchconfig: true
+`
+	file := path.Join(t.TempDir(), "config.yml")
+	os.WriteFile(file, []byte(original), 0644)
+
+	if err := SaveService(file, "driver", "executable: ./driver2.exe\ndependson: [events]\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveService(file, "hotkeys", "executable: ./hotkeys.exe\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteService(file, "events"); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `# Shell config
+
+nats:
+    url:   "nats://127.0.0.1:4222"
+
+services:
+
+    # Talks to windows
+    driver:
+      executable: ./driver2.exe
+      dependson: [events]
+    hotkeys:
+      executable: ./hotkeys.exe
+
+# Watch for changes
+watchconfig: true
+`
+	content, _ := os.ReadFile(file)
+	if string(content) != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
@@ -0,0 +1,245 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Variables which are defined for every service, in addition to the environment of the shell
+const (
+	// The directory of the shell executable
+	VarShellDir = "SHELL_DIR"
+	// The directory of the config file
+	VarConfigDir = "CONFIG_DIR"
+	// The home directory of the user
+	VarHome = "HOME"
+	// The name of the service
+	VarServiceName = "SERVICE_NAME"
+)
+
+// Expand '${VAR}' and '${VAR:-default}' in s.
+// The fields of a service are expanded with the variables of its environment and env file, the variables
+// SHELL_DIR, CONFIG_DIR, HOME and SERVICE_NAME, and the environment of the shell, in that order. The default is used if VAR is undefined or empty,
+// and may contain variables itself. '$$' is a literal '$'. Other uses of '$' are left as is.
+// Undefined variables without a default are errors.
+func Expand(s string, lookup func(string) (string, bool)) (string, error) {
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			result.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			result.WriteByte('$')
+			i += 1
+		case '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("'%s' is missing a closing brace.", s[i:])
+			}
+			value, err := expandVariable(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(value)
+			i = end
+		default:
+			result.WriteByte('$')
+		}
+	}
+	return result.String(), nil
+}
+
+// Get the index of the brace which closes the variable starting at start, or -1
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth += 1
+			i += 1
+		case s[i] == '}':
+			depth -= 1
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func expandVariable(v string, lookup func(string) (string, bool)) (string, error) {
+	name, fallback, hasDefault := strings.Cut(v, ":-")
+	if !validName(name) {
+		return "", fmt.Errorf("'%s' is not a valid variable name.", name)
+	}
+	value, ok := lookup(name)
+	if hasDefault && value == "" {
+		return Expand(fallback, lookup)
+	}
+	if !ok {
+		return "", fmt.Errorf("Variable '%s' is not defined.", name)
+	}
+	return value, nil
+}
+
+func validName(name string) bool {
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Parse the content of an env file. Every line is 'KEY=VALUE', optionally prefixed by 'export'.
+// Empty lines and lines starting with '#' are ignored. Values may be quoted. Single quoted values are
+// used as is, and other values are expanded with lookup, and the variables defined earlier in the file.
+func parseEnvFile(content []byte, lookup func(string) (string, bool)) ([]string, error) {
+	defined := map[string]string{}
+	local := func(name string) (string, bool) {
+		if value, ok := defined[name]; ok {
+			return value, true
+		}
+		return lookup(name)
+	}
+	var result []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validName(key) {
+			return nil, fmt.Errorf("Line %d is not 'KEY=VALUE'.", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		} else {
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			var err error
+			if value, err = Expand(value, local); err != nil {
+				return nil, fmt.Errorf("Line %d: %v", n, err)
+			}
+		}
+		defined[key] = value
+		result = append(result, key+"="+value)
+	}
+	return result, scanner.Err()
+}
+
+// Expand the variables in the executable, arguments, working directory and environment of a service,
+// and add the variables of its env file to its environment. Relative env files are relative to configDir.
+func (s Service) expand(name, configDir string) (Service, error) {
+	fail := func(field string, err error) (Service, error) {
+		return s, fmt.Errorf("Error in %s of service '%s'. %v", field, name, err)
+	}
+	defined := map[string]string{
+		VarConfigDir:   configDir,
+		VarServiceName: name,
+	}
+	if exe, err := os.Executable(); err == nil {
+		defined[VarShellDir] = filepath.ToSlash(filepath.Dir(exe))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		defined[VarHome] = filepath.ToSlash(home)
+	}
+	lookup := func(name string) (string, bool) {
+		if value, ok := defined[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+
+	var environment []string
+	if s.EnvFile != "" {
+		path, err := Expand(s.EnvFile, lookup)
+		if err != nil {
+			return fail("envfile", err)
+		}
+		if !filepath.IsAbs(path) && configDir != "" {
+			path = filepath.Join(configDir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fail("envfile", err)
+		}
+		if environment, err = parseEnvFile(content, lookup); err != nil {
+			return fail(path, err)
+		}
+		s.EnvFile = path
+	}
+	for _, entry := range environment {
+		key, value, _ := strings.Cut(entry, "=")
+		defined[key] = value
+	}
+	for _, entry := range s.Environment {
+		key, value, ok := strings.Cut(entry, "=")
+		if ok {
+			var err error
+			if value, err = Expand(value, lookup); err != nil {
+				return fail("environment", err)
+			}
+			defined[key] = value
+			entry = key + "=" + value
+		}
+		environment = append(environment, entry)
+	}
+	s.Environment = environment
+
+	var err error
+	if s.Executable, err = Expand(s.Executable, lookup); err != nil {
+		return fail("executable", err)
+	}
+	if s.WorkingDirectory, err = Expand(s.WorkingDirectory, lookup); err != nil {
+		return fail("workingdirectory", err)
+	}
+	arguments := make([]string, len(s.Arguments))
+	for i, arg := range s.Arguments {
+		if arguments[i], err = Expand(arg, lookup); err != nil {
+			return fail("arguments", err)
+		}
+	}
+	if s.Arguments != nil {
+		s.Arguments = arguments
+	}
+	return s, nil
+}
+
+// The directory of the config file. Empty if the config was not loaded from a file.
+func (c *Configuration) dir() string {
+	if c.Path == "" {
+		return ""
+	}
+	return filepath.ToSlash(filepath.Dir(c.Path))
+}
+
+// Expand the variables of every service
+func (c *Configuration) expand() error {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expanded, err := c.Services[name].expand(name, c.dir())
+		if err != nil {
+			return err
+		}
+		c.Services[name] = expanded
+	}
+	return nil
+}
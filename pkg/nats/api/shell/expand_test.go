@@ -0,0 +1,86 @@
+package shell
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"HOME": "C:/Users/me", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	cases := map[string]string{
+		"${HOME}/bin":                   "C:/Users/me/bin",
+		"${MISSING:-./default}":         "./default",
+		"${EMPTY:-${HOME}}":             "C:/Users/me",
+		"${HOME:-unused}":               "C:/Users/me",
+		"${EMPTY}":                      "",
+		"C$/share $HOME $$ $${HOME} $":  "C$/share $HOME $ ${HOME} $",
+		"${MISSING:-${MISSING:-deep}}!": "deep!",
+	}
+	for input, expected := range cases {
+		if result, err := Expand(input, lookup); err != nil || result != expected {
+			t.Fatalf("Expected '%s' to expand to '%s', got '%s' (%v)", input, expected, result, err)
+		}
+	}
+	for _, input := range []string{"${MISSING}", "${HOME", "${}", "${1A}", "${A B}", "${MISSING:-${MISSING}}"} {
+		if _, err := Expand(input, lookup); err == nil {
+			t.Fatalf("Expected '%s' to fail", input)
+		}
+	}
+}
+
+func TestExpandServices(t *testing.T) {
+	dir := t.TempDir()
+	env := `# Comment
+export ROOT=${CONFIG_DIR}/root
+LEVEL="debug"
+LITERAL='${NOT_EXPANDED}'
+`
+	if err := os.WriteFile(path.Join(dir, "events.env"), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `services:
+  events:
+    executable: ${ROOT}/${SERVICE_NAME}.exe
+    arguments: ['--level=${LEVEL}', '${LITERAL}']
+    workingdirectory: ${WORKDIR:-${ROOT}}
+    envfile: events.env
+    environment: [ 'LOG=${ROOT}/log', 'COMBINED=${LOG}.txt' ]
+`
+	cfg, err := ParseFile(path.Join(dir, "config.yml"), []byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := cfg.dir() + "/root"
+	events := cfg.Services["events"]
+	if events.Executable != root+"/events.exe" || events.WorkingDirectory != root {
+		t.Fatalf("Expected variables to be expanded, got %+v", events)
+	}
+	if strings.Join(events.Arguments, " ") != "--level=debug ${NOT_EXPANDED}" {
+		t.Fatalf("Unexpected arguments %v", events.Arguments)
+	}
+	expected := []string{"ROOT=" + root, "LEVEL=debug", "LITERAL=${NOT_EXPANDED}", "LOG=" + root + "/log", "COMBINED=" + root + "/log.txt"}
+	if strings.Join(events.Environment, ";") != strings.Join(expected, ";") {
+		t.Fatalf("Expected environment %v, got %v", expected, events.Environment)
+	}
+
+	// Errors are reported when the config is loaded
+	invalid := []string{
+		"services:\n  events:\n    executable: ${UNDEFINED_VARIABLE}/events.exe\n",
+		"services:\n  events:\n    executable: ./events.exe\n    envfile: missing.env\n",
+		"services:\n  events:\n    executable: ./events.exe\n    arguments: ['${unterminated']\n",
+	}
+	for _, content := range invalid {
+		if _, err := ParseFile(path.Join(dir, "config.yml"), []byte(content)); err == nil {
+			t.Fatalf("Expected an error for %s", content)
+		}
+	}
+	if _, err := cfg.WithService("driver", "executable: ${UNDEFINED_VARIABLE}"); err == nil {
+		t.Fatalf("Expected added services to be expanded")
+	}
+}
@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/operdies/windows-nats-shell/pkg/utils/cron"
+)
+
+// Check if the service runs to completion instead of running until it is stopped
+func (s Service) IsOneshot() bool {
+	return s.Kind == KindOneshot || (s.Kind == "" && s.Schedule != "")
+}
+
+// Get the parsed schedule of the service, or nil if it is not scheduled
+func (s Service) ParseSchedule() (*cron.Schedule, error) {
+	if s.Schedule == "" {
+		return nil, nil
+	}
+	return cron.Parse(s.Schedule)
+}
+
+func (s Service) validate(name string) error {
+	switch s.Kind {
+	case "", KindDaemon, KindOneshot:
+	default:
+		return fmt.Errorf("Service '%s' has unknown kind '%s'.", name, s.Kind)
+	}
+	// Pings do not detect a stuck handler, so restarting a service which only answers pings would never help
+	if s.Health.Restart && s.Health.Subject == "" {
+		return fmt.Errorf("Service '%s' restarts when it is unhealthy, but its health check has no subject.", name)
+	}
+	if s.Schedule == "" {
+		return nil
+	}
+	if !s.IsOneshot() {
+		return fmt.Errorf("Service '%s' has a schedule, but only oneshot services can be scheduled.", name)
+	}
+	if s.Detach {
+		return fmt.Errorf("Service '%s' has a schedule, but detached services cannot be scheduled.", name)
+	}
+	_, err := s.ParseSchedule()
+	return err
+}
+
+// Validate every service, and the dependencies between them
+func (c *Configuration) validate() error {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.Services[name].validate(name); err != nil {
+			return err
+		}
+	}
+	_, err := c.StartOrder()
+	return err
+}
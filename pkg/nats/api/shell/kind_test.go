@@ -0,0 +1,33 @@
+package shell
+
+import (
+	"testing"
+)
+
+func TestServiceKinds(t *testing.T) {
+	cfg, err := Parse([]byte(testConfig + `  reindex:
+    executable: ./reindex.exe
+    schedule: 0 3 * * *
+  setup:
+    executable: ./setup.exe
+    kind: oneshot
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Services["reindex"].IsOneshot() || !cfg.Services["setup"].IsOneshot() || cfg.Services["events"].IsOneshot() {
+		t.Fatalf("Expected reindex and setup to be oneshot services")
+	}
+
+	invalid := map[string]string{
+		"unknown kind":       "executable: ./a.exe\nkind: cron",
+		"invalid schedule":   "executable: ./a.exe\nschedule: every day",
+		"scheduled daemon":   "executable: ./a.exe\nkind: daemon\nschedule: '@daily'",
+		"scheduled detached": "executable: ./a.exe\ndetach: true\nschedule: '@daily'",
+	}
+	for name, definition := range invalid {
+		if _, err := cfg.WithService("a", definition); err == nil {
+			t.Fatalf("Expected %s to be invalid", name)
+		}
+	}
+}
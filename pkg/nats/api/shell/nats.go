@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Connection settings shared by the shell and every service it starts.
+// The shell reads them from the 'nats' section of its config, and passes
+// them on to services through environment variables.
+type NatsConfig struct {
+	// The server url. Defaults to nats://127.0.0.1:4222
+	Url string
+	// The name of the connection. Services are named after the service.
+	Name string
+	// Subscribers also answer requests on subjects prefixed with the namespace,
+	// e.g. 'driver.Windows.GetWindows'. Services use their service name.
+	Namespace string
+	// Path to a user credentials file
+	Credentials string
+	// Token authentication
+	Token string
+	// User / password authentication
+	User     string
+	Password string
+	// The number of reconnect attempts before giving up. A negative value reconnects forever.
+	MaxReconnects *int
+	// Time between reconnect attempts in milliseconds
+	ReconnectWait int
+	// Time between pings to the server in milliseconds
+	PingInterval int
+	// Time to wait for replies to requests in milliseconds
+	Timeout int
+	// Record a span for every handled message, so interactions can be followed with Shell.Trace
+	Trace bool
+}
+
+// Get a copy of the settings without the token and password, so they can be shared with clients
+func (n NatsConfig) Redacted() NatsConfig {
+	n.Token = ""
+	n.Password = ""
+	return n
+}
+
+const (
+	NATS_URL_ENV_KEY            = "_SHELL_NATS_URL_"
+	NATS_CREDENTIALS_ENV_KEY    = "_SHELL_NATS_CREDENTIALS_"
+	NATS_TOKEN_ENV_KEY          = "_SHELL_NATS_TOKEN_"
+	NATS_USER_ENV_KEY           = "_SHELL_NATS_USER_"
+	NATS_PASSWORD_ENV_KEY       = "_SHELL_NATS_PASSWORD_"
+	NATS_MAX_RECONNECTS_ENV_KEY = "_SHELL_NATS_MAX_RECONNECTS_"
+	NATS_RECONNECT_WAIT_ENV_KEY = "_SHELL_NATS_RECONNECT_WAIT_"
+	NATS_PING_INTERVAL_ENV_KEY  = "_SHELL_NATS_PING_INTERVAL_"
+	NATS_TIMEOUT_ENV_KEY        = "_SHELL_NATS_TIMEOUT_"
+	NATS_TRACE_ENV_KEY          = "_SHELL_NATS_TRACE_"
+)
+
+// Get the environment variables which pass these settings on to a service.
+// Unset values are omitted.
+func (n NatsConfig) Environment() []string {
+	var env []string
+	add := func(key, value string) {
+		if value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	addInt := func(key string, value int) {
+		if value != 0 {
+			add(key, strconv.Itoa(value))
+		}
+	}
+	add(NATS_URL_ENV_KEY, n.Url)
+	add(NATS_CREDENTIALS_ENV_KEY, n.Credentials)
+	add(NATS_TOKEN_ENV_KEY, n.Token)
+	add(NATS_USER_ENV_KEY, n.User)
+	add(NATS_PASSWORD_ENV_KEY, n.Password)
+	if n.MaxReconnects != nil {
+		add(NATS_MAX_RECONNECTS_ENV_KEY, strconv.Itoa(*n.MaxReconnects))
+	}
+	addInt(NATS_RECONNECT_WAIT_ENV_KEY, n.ReconnectWait)
+	addInt(NATS_PING_INTERVAL_ENV_KEY, n.PingInterval)
+	addInt(NATS_TIMEOUT_ENV_KEY, n.Timeout)
+	if n.Trace {
+		add(NATS_TRACE_ENV_KEY, "true")
+	}
+	return env
+}
+
+// Read the settings passed on by the shell. The connection is named after the service.
+func NatsConfigFromEnvironment() (n NatsConfig) {
+	getInt := func(key string) int {
+		v, _ := strconv.Atoi(os.Getenv(key))
+		return v
+	}
+	n.Url = os.Getenv(NATS_URL_ENV_KEY)
+	n.Name = os.Getenv(SERVICE_ENV_KEY)
+	n.Namespace = n.Name
+	n.Credentials = os.Getenv(NATS_CREDENTIALS_ENV_KEY)
+	n.Token = os.Getenv(NATS_TOKEN_ENV_KEY)
+	n.User = os.Getenv(NATS_USER_ENV_KEY)
+	n.Password = os.Getenv(NATS_PASSWORD_ENV_KEY)
+	if v, err := strconv.Atoi(os.Getenv(NATS_MAX_RECONNECTS_ENV_KEY)); err == nil {
+		n.MaxReconnects = &v
+	}
+	n.ReconnectWait = getInt(NATS_RECONNECT_WAIT_ENV_KEY)
+	n.PingInterval = getInt(NATS_PING_INTERVAL_ENV_KEY)
+	n.Timeout = getInt(NATS_TIMEOUT_ENV_KEY)
+	n.Trace, _ = strconv.ParseBool(os.Getenv(NATS_TRACE_ENV_KEY))
+	return
+}
@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNatsConfigEnvironment(t *testing.T) {
+	forever := -1
+	cfg := NatsConfig{
+		Url:           "nats://127.0.0.1:4333",
+		Token:         "s3cr3t",
+		MaxReconnects: &forever,
+		PingInterval:  500,
+		Trace:         true,
+	}
+
+	for _, kv := range cfg.Environment() {
+		parts := strings.SplitN(kv, "=", 2)
+		t.Setenv(parts[0], parts[1])
+	}
+	t.Setenv(SERVICE_ENV_KEY, "driver")
+
+	actual := NatsConfigFromEnvironment()
+	if actual.Url != cfg.Url || actual.Token != cfg.Token || actual.PingInterval != cfg.PingInterval {
+		t.Fatalf("Expected %+v, got %+v", cfg, actual)
+	}
+	if !actual.Trace {
+		t.Fatalf("Expected Trace to be set")
+	}
+	if actual.MaxReconnects == nil || *actual.MaxReconnects != forever {
+		t.Fatalf("Expected MaxReconnects to be %d", forever)
+	}
+	if actual.Name != "driver" {
+		t.Fatalf("Expected the connection to be named after the service, got '%s'", actual.Name)
+	}
+	if actual.ReconnectWait != 0 || actual.Credentials != "" {
+		t.Fatalf("Expected unset values to be empty, got %+v", actual)
+	}
+}
+
+func TestNatsConfigRedacted(t *testing.T) {
+	cfg := NatsConfig{Url: "nats://127.0.0.1:4333", Token: "s3cr3t", User: "shell", Password: "hunter2"}
+	redacted := cfg.Redacted()
+	if redacted.Token != "" || redacted.Password != "" {
+		t.Fatalf("Expected the secrets to be removed, got %+v", redacted)
+	}
+	if redacted.Url != cfg.Url || redacted.User != cfg.User {
+		t.Fatalf("Expected the other settings to be kept, got %+v", redacted)
+	}
+	if cfg.Token != "s3cr3t" {
+		t.Fatalf("Expected the original settings to be unchanged")
+	}
+}
@@ -3,31 +3,58 @@ package shell
 import (
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
+	"github.com/operdies/windows-nats-shell/pkg/input/mouse"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	// Restart a service by name
+	// Restart a service by name. Changes to the config file are applied with ReloadConfig
 	RestartService = "Shell.RestartService"
-	// Stop a service by name. AutoRestarting services
+	// Stop a service by name. Services with a restart policy
 	// will not be restarted until the service is restarted,
 	// or the shell is reloaded.
 	StopService = "Shell.StopService"
-	// Start a service by name
+	// Start a service by name. Scheduled services resume their schedule instead.
 	StartService = "Shell.StartService"
+	// Run a oneshot service by name immediately, regardless of its schedule
+	RunNow = "Shell.RunNow"
+	// A run of a oneshot service completed
+	ServiceCompleted = "Shell.ServiceCompleted"
+	// The process of a service started
+	ServiceStarted = "Shell.Service.Started"
+	// The process of a service exited, or failed to start
+	ServiceExited = "Shell.Service.Exited"
+	// A service which exited will be restarted after a delay
+	ServiceRestarting = "Shell.Service.Restarting"
+	// A service restarted too often, and the shell gave up on it
+	ServiceFailed = "Shell.Service.Failed"
+	// Every lifecycle event of every service
+	ServiceEvents = "Shell.Service.>"
+	// Ask a service to exit. Only sent to the namespace of the service, e.g. 'driver.Shell.Shutdown'
+	Shutdown = "Shell.Shutdown"
 	// Restart the shell
 	RestartShell = "Shell.Restart"
+	// Reload the config file, and only restart the services whose config changed
+	ReloadConfig = "Shell.Reload"
 	// Get the full shell config
 	ShellConfig = "Shell.ShellConfig"
 	// Get the config of a loaded service
 	GetService = "Shell.Config"
-	// Set a new config
+	// Replace the config, and restart the services whose config changed
 	SetConfig = "Shell.SetConfig"
-	// Add a new service
+	// Add a new service, and start it. Persisting the service rewrites the config file,
+	// which drops its blank lines and normalizes its indentation.
 	AddService = "Shell.AddService"
-	// Remove an existing service
+	// Stop and remove an existing service. Persisting the removal rewrites the config file,
+	// which drops its blank lines and normalizes its indentation.
 	RemoveService = "Shell.RemoveService"
 	// Quit the shell
 	QuitShell = "Shell.Quit"
@@ -41,6 +68,49 @@ const (
 	ToggleBackground = "Shell.ToggleBackground"
 	// Send a toast
 	ShellToast = "Shell.Toast"
+	// Get the recorded spans of a trace
+	Trace = "Shell.Trace"
+	// A service handled a traced message
+	TraceSpan = "Shell.TraceSpan"
+	// A service is ready to handle requests
+	ServiceReady = "Shell.ServiceReady"
+	// Get the status of every configured service
+	ListServices = "Shell.ListServices"
+	// Get the status of a service by name
+	ServiceStatus = "Shell.ServiceStatus"
+)
+
+var (
+	RestartServiceEndpoint   = api.Endpoint[string, api.Empty]{Subject: RestartService}
+	StopServiceEndpoint      = api.Endpoint[string, api.Empty]{Subject: StopService}
+	StartServiceEndpoint     = api.Endpoint[string, api.Empty]{Subject: StartService}
+	RunNowEndpoint           = api.Endpoint[string, api.Empty]{Subject: RunNow}
+	ServiceCompletedEvent    = api.Event[ServiceRun]{Subject: ServiceCompleted}
+	ServiceStartedEvent      = api.Event[ServiceEvent]{Subject: ServiceStarted}
+	ServiceExitedEvent       = api.Event[ServiceEvent]{Subject: ServiceExited}
+	ServiceRestartingEvent   = api.Event[ServiceEvent]{Subject: ServiceRestarting}
+	ServiceFailedEvent       = api.Event[ServiceEvent]{Subject: ServiceFailed}
+	ServiceLifecycleEvents   = api.Event[ServiceEvent]{Subject: ServiceEvents}
+	ShutdownEndpoint         = api.Endpoint[api.Empty, api.Empty]{Subject: Shutdown}
+	RestartShellEndpoint     = api.Endpoint[api.Empty, api.Empty]{Subject: RestartShell}
+	ReloadConfigEndpoint     = api.Endpoint[api.Empty, ConfigDiff]{Subject: ReloadConfig}
+	QuitShellEndpoint        = api.Endpoint[api.Empty, api.Empty]{Subject: QuitShell}
+	ShellConfigEndpoint      = api.Endpoint[api.Empty, Configuration]{Subject: ShellConfig}
+	GetServiceEndpoint       = api.Endpoint[string, any]{Subject: GetService}
+	ToggleBackgroundEndpoint = api.Endpoint[api.Empty, bool]{Subject: ToggleBackground}
+	// Input events are published, but subscribers may reply whether the event was handled
+	MouseEventEndpoint    = api.Endpoint[mouse.MouseEventInfo, bool]{Subject: MouseEvent}
+	KeyboardEventEndpoint = api.Endpoint[keyboard.KeyboardEventInfo, bool]{Subject: KeyboardEvent}
+	ShellEvents           = api.Event[ShellEventInfo]{Subject: ShellEvent}
+	ShellToastEvent       = api.Event[Toast]{Subject: ShellToast}
+	TraceEndpoint         = api.Endpoint[string, []trace.Span]{Subject: Trace}
+	TraceSpans            = api.Event[trace.Span]{Subject: TraceSpan}
+	ServiceReadyEvent     = api.Event[string]{Subject: ServiceReady}
+	ListServicesEndpoint  = api.Endpoint[api.Empty, []ServiceStatusInfo]{Subject: ListServices}
+	ServiceStatusEndpoint = api.Endpoint[string, ServiceStatusInfo]{Subject: ServiceStatus}
+	SetConfigEndpoint     = api.Endpoint[SetConfigRequest, ConfigDiff]{Subject: SetConfig}
+	AddServiceEndpoint    = api.Endpoint[AddServiceRequest, api.Empty]{Subject: AddService}
+	RemoveServiceEndpoint = api.Endpoint[RemoveServiceRequest, api.Empty]{Subject: RemoveService}
 )
 
 const (
@@ -73,17 +143,190 @@ type Service struct {
 	// Defaults to cwd
 	WorkingDirectory string
 	Enabled          *bool
-	AutoRestart      *bool
-	Visible          bool
-	// Any environment variables that should be defined
+	// How the shell runs the service. Defaults to daemon, or oneshot if the service has a schedule.
+	Kind ServiceKind
+	// Run the service whenever this cron expression matches, e.g. '0 3 * * *' or '@every 1h'.
+	// Scheduled services must be oneshot services, and are not started with the shell.
+	// A scheduled run is skipped if the previous run has not completed.
+	Schedule string
+	// Services which must be ready before this service is started. Scheduled services wait for them before each run.
+	DependsOn []string
+	// Deprecated: use Restart. AutoRestart is equivalent to 'always'.
+	AutoRestart *bool
+	// When the service is restarted after it exits. Defaults to never.
+	Restart RestartPolicy
+	// How long to wait between restarts
+	Backoff Backoff
+	// The service is marked failed if it is restarted more than MaxRestarts
+	// times within RestartWindow milliseconds. Defaults to 5 restarts per minute.
+	MaxRestarts   int
+	RestartWindow int
+	// Check that the service is responsive while it is running
+	Health HealthCheck
+	// Time in milliseconds to wait for the service to exit after it is asked to shut down,
+	// before it is killed. Defaults to 5000. A negative value kills the service immediately.
+	GracePeriod int
+	// Leave the processes started by the service running when it stops, e.g. programs started by a launcher.
+	// By default, every process started by the service is killed when the service stops.
+	KeepChildren bool
+	Visible      bool
+	// Any environment variables that should be defined, e.g. 'KEY=VALUE'.
+	// Executable, Arguments, WorkingDirectory, Environment and EnvFile may refer to variables
+	// as '${VAR}' or '${VAR:-default}'. See Expand for the variables which are defined.
 	Environment []string
-	Detach      bool
-	Admin       bool
+	// A file of 'KEY=VALUE' lines which are added to the environment before Environment.
+	// Relative paths are relative to the directory of the config file.
+	EnvFile string
+	Detach  bool
+	Admin   bool
+}
+
+type ServiceKind = string
+
+const (
+	// The service runs until it is stopped
+	KindDaemon ServiceKind = "daemon"
+	// The service runs to completion. Services which depend on it are started once it succeeds.
+	KindOneshot ServiceKind = "oneshot"
+)
+
+type ServiceState = string
+
+const (
+	// The service is waiting for the services it depends on before it starts
+	StateStarting ServiceState = "starting"
+	// The process of the service is running
+	StateRunning ServiceState = "running"
+	// The service was asked to stop, and the shell is waiting for its process to exit
+	StateStopping ServiceState = "stopping"
+	// The service is not running, and will not be restarted
+	StateStopped ServiceState = "stopped"
+	// The service exited with a non-zero exit code, and will not be restarted
+	StateCrashed ServiceState = "crashed"
+	// The oneshot service ran to completion
+	StateSucceeded ServiceState = "succeeded"
+	// The scheduled service is waiting for its next run
+	StateScheduled ServiceState = "scheduled"
+	// The service exited, and is waiting to be restarted
+	StateBackingOff ServiceState = "backing-off"
+	// The service restarted too often, and the shell gave up on it
+	StateFailed ServiceState = "failed"
+	// The service was started detached, so the shell does not know its state
+	StateDetached ServiceState = "detached"
+	// The service is not enabled
+	StateDisabled ServiceState = "disabled"
+)
+
+// What the shell knows about a service
+type ServiceStatusInfo struct {
+	Name  string
+	State ServiceState
+	// The process id while the service is running
+	Pid        int
+	StartCount int
+	// How long the service has been running
+	Uptime time.Duration
+	// Set while the health check of the service is failing
+	Unhealthy bool
+	// The exit code of the last time the service exited
+	LastExitCode int
+	// The error of the last time the service failed to start or exited
+	LastError string
+	// When a scheduled service runs next. Zero if the service is not scheduled.
+	NextRun time.Time
+}
+
+// The outcome of a run of a oneshot service
+type ServiceRun struct {
+	Name    string
+	Started time.Time
+	// How long the service ran
+	Duration time.Duration
+	ExitCode int
+	// Set if the service failed to start, or did not exit cleanly
+	Error string
+}
+
+// Check if the run exited cleanly with exit code 0
+func (r ServiceRun) Succeeded() bool {
+	return r.ExitCode == 0 && r.Error == ""
+}
+
+// A change in the lifecycle of a service. Which fields are set depends on the event.
+type ServiceEvent struct {
+	// The subject of the event, e.g. ServiceExited
+	Event string
+	Name  string
+	// The process id of the service. Set for started and exited events.
+	Pid int
+	// How many times the service was started
+	StartCount int
+	// The exit code of the process. Set for exited events.
+	ExitCode int
+	// The signal which terminated the process, e.g. 'killed'. Only set on platforms with signals.
+	Signal string
+	// How long the process ran. Set for exited events.
+	Uptime time.Duration
+	// How many times the service restarted within its restart window. Set for restarting and failed events.
+	Restarts int
+	// How long until the service is restarted. Set for restarting events.
+	Delay time.Duration
+	// Why the process failed to start or exit cleanly, or why the shell gave up on the service
+	Error string
+}
+
+// A request sent periodically to a running service
+type HealthCheck struct {
+	// The subject to request in the namespace of the service. Defaults to the ping subject
+	// which every client answers automatically. Pings only detect that the service is
+	// connected, so set the subject of a handler to detect that the handler is stuck.
+	// A subject is required to restart the service when it is unhealthy.
+	Subject string
+	// The payload of the request
+	Payload any
+	// The expected result. If not set, any successful reply is healthy.
+	Expect any
+	// Time between checks in milliseconds. Health checks are disabled if this is 0.
+	Interval int
+	// Time to wait for a reply in milliseconds. Defaults to the request timeout.
+	Timeout int
+	// Consecutive failed checks before the service is unhealthy. Defaults to 3.
+	Threshold int
+	// Restart the service when it becomes unhealthy
+	Restart bool
+}
+
+type RestartPolicy = string
+
+const (
+	// Never restart the service
+	RestartNever RestartPolicy = "never"
+	// Restart the service if it exits with a non-zero exit code
+	RestartOnFailure RestartPolicy = "on-failure"
+	// Restart the service whenever it exits
+	RestartAlways RestartPolicy = "always"
+)
+
+// Exponential backoff. The delay before the n'th consecutive restart is
+// Initial * Multiplier^(n-1), at most Max, randomly adjusted by up to Jitter of itself.
+type Backoff struct {
+	// The first delay in milliseconds. Defaults to 500.
+	Initial int
+	// The largest delay in milliseconds. Defaults to 30000.
+	Max int
+	// Defaults to 2
+	Multiplier float64
+	// A fraction between 0 and 1. Defaults to 0.2.
+	Jitter *float64
 }
 
 type Configuration struct {
 	// Path to the file the config was loaded from
 	Path string
+	// How the shell and its services connect to NATS
+	Nats NatsConfig
+	// Reload the config when the config file changes
+	WatchConfig bool
 	// A typed map of named services and the configuration options known by the shell.
 	Services map[string]Service
 	// An untyped map of named services and their specific configurations. The service
@@ -156,6 +399,17 @@ func parseCfg(path string) (config *Configuration, err error) {
 	if err != nil {
 		return
 	}
+	return ParseFile(path, content)
+}
+
+// Parse and validate the content of a config file
+func Parse(content []byte) (config *Configuration, err error) {
+	return ParseFile("", content)
+}
+
+// Parse and validate the content of the config file at path.
+// Variables in the services are expanded, and relative env files are relative to path.
+func ParseFile(path string, content []byte) (config *Configuration, err error) {
 	var cfg Configuration
 	err = yaml.Unmarshal(content, &cfg)
 	if err != nil {
@@ -166,6 +420,15 @@ func parseCfg(path string) (config *Configuration, err error) {
 	config = &cfg
 	config.Path = path
 	config.ServiceConfigs = cfgHelper.Services
+	if err != nil {
+		return
+	}
+	if err = config.expand(); err != nil {
+		return nil, err
+	}
+	if err = config.validate(); err != nil {
+		config = nil
+	}
 	return
 }
 
@@ -195,13 +458,14 @@ func getExeDir() string {
 }
 
 func getConfigPaths() []string {
-	result := make([]string, 1)
-	appdata, _ := os.UserHomeDir()
-	result[0] = path.Join(appdata, "AppData", "Local", "windows-nats-shell", "config.yml")
-	exeDir := getExeDir()
-	fix := func(s string) string {
-		return strings.ReplaceAll(s, `/`, `\`)
+	var result []string
+	if runtime.GOOS == "windows" {
+		appdata, _ := os.UserHomeDir()
+		result = append(result, path.Join(appdata, "AppData", "Local", "windows-nats-shell", "config.yml"))
+	} else if configDir, err := os.UserConfigDir(); err == nil {
+		result = append(result, path.Join(configDir, "windows-nats-shell", "config.yml"))
 	}
+	exeDir := getExeDir()
 	if exeDir != "" {
 		result = append(result, path.Join(exeDir, "config.yml"))
 	}
@@ -210,7 +474,7 @@ func getConfigPaths() []string {
 	result = append(result, path.Join(wd, "config.yml"))
 
 	for i := range result {
-		result[i] = fix(result[i])
+		result[i] = filepath.FromSlash(result[i])
 	}
 
 	return result
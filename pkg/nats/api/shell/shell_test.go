@@ -0,0 +1,20 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestartRequiresHealthSubject(t *testing.T) {
+	cfg := &Configuration{Services: map[string]Service{
+		"driver": {Health: HealthCheck{Interval: 5000, Restart: true}},
+	}}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "driver") {
+		t.Fatalf("Expected restarting on a ping to be rejected, got %v", err)
+	}
+
+	cfg.Services["driver"] = Service{Health: HealthCheck{Subject: "Windows.GetWindows", Interval: 5000, Restart: true}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,7 @@
 package system
 
+import "github.com/operdies/windows-nats-shell/pkg/nats/api"
+
 const (
 	// Get executables in path, and any program from the startmenu
 	GetPrograms = "System.GetPrograms"
@@ -8,3 +10,9 @@ const (
 	LaunchProgram        = "System.LaunchProgram"
 	LaunchProgramAsAdmin = "System.LaunchProgramAsAdmin"
 )
+
+var (
+	GetProgramsEndpoint          = api.Endpoint[api.Empty, []string]{Subject: GetPrograms}
+	LaunchProgramEndpoint        = api.Endpoint[string, api.Empty]{Subject: LaunchProgram}
+	LaunchProgramAsAdminEndpoint = api.Endpoint[string, api.Empty]{Subject: LaunchProgramAsAdmin}
+)
@@ -2,6 +2,9 @@ package windows
 
 import (
 	"math"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/wintypes"
 )
 
 const (
@@ -37,6 +40,37 @@ const (
 	ToggleBorder = "Window.ToggleBorder"
 )
 
+// Window handles of 0 refer to the foreground window where it makes sense
+var (
+	GetWindowsEndpoint              = api.Endpoint[api.Empty, []wintypes.Window]{Subject: GetWindows}
+	WindowsUpdatedEvent             = api.Event[[]wintypes.Window]{Subject: WindowsUpdated}
+	IsWindowFocusedEndpoint         = api.Endpoint[wintypes.HWND, bool]{Subject: IsWindowFocused}
+	FocusWindowEndpoint             = api.Endpoint[wintypes.HWND, bool]{Subject: FocusWindow}
+	MoveWindowEndpoint              = api.Endpoint[MoveEvent, bool]{Subject: MoveWindow}
+	ResizeWindowEndpoint            = api.Endpoint[ResizeEvent, bool]{Subject: ResizeWindow}
+	MinimizeWindowEndpoint          = api.Endpoint[wintypes.HWND, bool]{Subject: MinimizeWindow}
+	RestoreWindowEndpoint           = api.Endpoint[wintypes.HWND, bool]{Subject: RestoreWindow}
+	MaximizeWindowEndpoint          = api.Endpoint[wintypes.HWND, bool]{Subject: MaximizeWindow}
+	RestoreOrMinimizeWindowEndpoint = api.Endpoint[wintypes.HWND, bool]{Subject: RestoreOrMinimizeWindow}
+	FocusPreviousEndpoint           = api.Endpoint[wintypes.HWND, bool]{Subject: FocusPrevious}
+	FocusNextEndpoint               = api.Endpoint[wintypes.HWND, bool]{Subject: FocusNext}
+	HideBorderEndpoint              = api.Endpoint[wintypes.HWND, bool]{Subject: HideBorder}
+	ShowBorderEndpoint              = api.Endpoint[wintypes.HWND, bool]{Subject: ShowBorder}
+	ToggleBorderEndpoint            = api.Endpoint[wintypes.HWND, bool]{Subject: ToggleBorder}
+)
+
+type MoveEvent struct {
+	Handle wintypes.HWND
+	// The new position of the top left corner of the window
+	To Point
+}
+
+type ResizeEvent struct {
+	Handle wintypes.HWND
+	Width  int32
+	Height int32
+}
+
 type CardinalDirection = int
 
 const (
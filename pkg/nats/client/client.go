@@ -1,9 +1,17 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"log"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/codec"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
 )
 
 var subscriber interface {
@@ -12,52 +20,273 @@ var subscriber interface {
 type Client struct {
 	nc        *nats.Conn
 	timeout   time.Duration
+	closed    chan struct{}
+	shutdown  *shutdown
 	Subscribe *Subscriber
 	Publish   *Publisher
 	Request   *Requester
 }
 
 type Subscriber struct {
-	nc      *nats.Conn
-	timeout time.Duration
+	nc         *nats.Conn
+	timeout    time.Duration
+	namespace  *namespace
+	tracker    *tracker
+	middleware *middlewares
+	// The service spans are recorded for, if tracing is enabled
+	service string
+	record  bool
 }
 
 type Publisher struct {
-	nc      *nats.Conn
-	timeout time.Duration
+	nc       *nats.Conn
+	timeout  time.Duration
+	codec    codec.Codec
+	instance string
+	ctx      context.Context
+	// Add trace headers to messages, if tracing is enabled
+	trace bool
 }
 
 type Requester struct {
-	nc      *nats.Conn
-	timeout time.Duration
+	nc       *nats.Conn
+	timeout  time.Duration
+	codec    codec.Codec
+	instance string
+	// Add trace headers to requests, if tracing is enabled
+	trace bool
 }
 
-func (client Client) Close() {
-	client.nc.Close()
+// Get the underlying connection for subjects which are not covered by the client
+func (client Client) Conn() *nats.Conn {
+	return client.nc
 }
 
+// Connect using the settings passed on by the shell
 func Default() Client {
-	c, err := New(nats.DefaultURL, time.Second)
+	c, err := Connect(shell.NatsConfigFromEnvironment())
 	if err != nil {
 		panic(err)
 	}
 	return c
 }
 
-func New(url string, timeout time.Duration) (c Client, err error) {
-	nc, err := nats.Connect(url)
+// Get the nats options described by a connection config
+func Options(cfg shell.NatsConfig) []nats.Option {
+	ms := func(v int) time.Duration { return time.Millisecond * time.Duration(v) }
+	var opts []nats.Option
+	if cfg.Name != "" {
+		opts = append(opts, nats.Name(cfg.Name))
+	}
+	if cfg.Credentials != "" {
+		opts = append(opts, nats.UserCredentials(cfg.Credentials))
+	}
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+	if cfg.User != "" {
+		opts = append(opts, nats.UserInfo(cfg.User, cfg.Password))
+	}
+	if cfg.MaxReconnects != nil {
+		opts = append(opts, nats.MaxReconnects(*cfg.MaxReconnects))
+	}
+	if cfg.ReconnectWait > 0 {
+		opts = append(opts, nats.ReconnectWait(ms(cfg.ReconnectWait)))
+	}
+	if cfg.PingInterval > 0 {
+		opts = append(opts, nats.PingInterval(ms(cfg.PingInterval)))
+	}
+	return opts
+}
+
+// Get the server url of a connection config
+func Url(cfg shell.NatsConfig) string {
+	if cfg.Url == "" {
+		return nats.DefaultURL
+	}
+	return cfg.Url
+}
+
+// Connect to the server described by a connection config
+func Connect(cfg shell.NatsConfig) (Client, error) {
+	timeout := time.Second
+	if cfg.Timeout > 0 {
+		timeout = time.Millisecond * time.Duration(cfg.Timeout)
+	}
+	return dial(Url(cfg), timeout, cfg, Options(cfg)...)
+}
+
+func New(url string, timeout time.Duration, options ...nats.Option) (Client, error) {
+	return dial(url, timeout, shell.NatsConfig{}, options...)
+}
+
+func dial(url string, timeout time.Duration, cfg shell.NatsConfig, options ...nats.Option) (c Client, err error) {
+	c.closed = make(chan struct{})
+	c.shutdown = &shutdown{}
+	closed := func(*nats.Conn) { close(c.closed) }
+	nc, err := nats.Connect(url, append(options, nats.ClosedHandler(closed))...)
 	if err != nil {
 		return
 	}
-	c.Publish = &Publisher{nc, timeout}
-	c.Request = &Requester{nc, timeout}
-	c.Subscribe = &Subscriber{nc, timeout}
+	c.nc = nc
+	c.timeout = timeout
+	c.Publish = &Publisher{nc, timeout, codec.Default, "", nil, cfg.Trace}
+	c.Request = &Requester{nc, timeout, codec.Default, "", cfg.Trace}
+	c.Subscribe = &Subscriber{nc, timeout, newNamespace(), &tracker{}, &middlewares{}, nc.Opts.Name, cfg.Trace}
+	c.Subscribe.namespace.name = cfg.Namespace
+	if err = c.Subscribe.describe(); err == nil {
+		_, err = c.Subscribe.Ping(func() string { return nc.Opts.Name })
+	}
+	if err != nil {
+		nc.Close()
+	}
 	return
 }
 
-func errorOrOk(err error) []byte {
+// Get a publisher which encodes payloads with the given codec
+func (client Publisher) WithCodec(c codec.Codec) Publisher {
+	client.codec = c
+	return client
+}
+
+// Get a publisher whose messages belong to the trace of ctx
+func (client Publisher) WithContext(ctx context.Context) Publisher {
+	client.ctx = ctx
+	return client
+}
+
+// Get a requester which encodes payloads with the given codec.
+// Subscribers reply using the same codec.
+func (client Requester) WithCodec(c codec.Codec) Requester {
+	client.codec = c
+	return client
+}
+
+// Get the codec a message was encoded with
+func codecOf(msg *nats.Msg) (codec.Codec, error) {
+	return codec.ForContentType(msg.Header.Get(codec.Header))
+}
+
+// Create a message with an encoded payload. A nil or api.Empty payload results in an empty message.
+func newMsg(subject string, c codec.Codec, payload any) (*nats.Msg, error) {
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(codec.Header, c.ContentType())
+	if _, empty := payload.(api.Empty); payload != nil && !empty {
+		data, err := c.Encode(payload)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = data
+	}
+	return msg, nil
+}
+
+// Publish an arbitrary payload to an arbitrary subject
+func (client Publisher) Message(subject string, payload any) error {
+	return client.publish(subject, payload)
+}
+
+func (client Publisher) publish(subject string, payload any) error {
+	msg, err := newMsg(qualify(client.instance, subject), client.codec, payload)
+	if err != nil {
+		return err
+	}
+	if client.trace {
+		trace.Inject(client.ctx, msg.Header)
+	}
+	return client.nc.PublishMsg(msg)
+}
+
+// Decode the payload of a message. If the payload cannot be decoded,
+// the sender is told why if it expects a reply, and err is not nil.
+func decode[T any](msg *nats.Msg) (result T, err error) {
+	c, err := codecOf(msg)
 	if err == nil {
-		return []byte("Ok")
+		result, err = codec.Decode[T](c, msg.Data)
+	}
+	if err != nil {
+		err = reply.Errorf(reply.InvalidRequest, "%s: %v", msg.Subject, err)
+		if msg.Reply != "" {
+			// The result is nil, so it decodes into any type the requester expects
+			respond[any](msg, nil, err)
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	return result, err
+}
+
+// Classify errors from the nats connection so callers can tell them apart from failed requests
+func requestError(subject string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return reply.Errorf(reply.Canceled, "%s: %v", subject, err)
+	}
+	if errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return reply.Errorf(reply.Timeout, "%s: %v", subject, err)
+	}
+	if errors.Is(err, nats.ErrNoResponders) {
+		return reply.Errorf(reply.NoResponders, "%s: %v", subject, err)
+	}
+	return reply.Errorf(reply.Failed, "%s: %v", subject, err)
+}
+
+// Send a request and unwrap the envelope in the reply
+func request[T any](ctx context.Context, client Requester, subject string, payload any) (result T, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.timeout)
+		defer cancel()
+	}
+	msg, err := newMsg(subject, client.codec, payload)
+	if err != nil {
+		err = reply.Errorf(reply.InvalidRequest, "%s: %v", subject, err)
+		return
+	}
+	msg.Subject = qualify(client.instance, subject)
+	if client.trace {
+		trace.Inject(ctx, msg.Header)
+	}
+	response, err := client.nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		err = requestError(subject, err)
+		return
+	}
+	return decodeReply[T](subject, response)
+}
+
+// Unwrap the envelope in the reply to a request
+func decodeReply[T any](subject string, response *nats.Msg) (result T, err error) {
+	c, err := codecOf(response)
+	if err != nil {
+		err = reply.Errorf(reply.Failed, "%s: %v", subject, err)
+		return
+	}
+	envelope, err := codec.Decode[reply.Envelope[T]](c, response.Data)
+	if err != nil {
+		err = reply.Errorf(reply.Failed, "%s: malformed reply: %v", subject, err)
+		return
+	}
+	return envelope.Unwrap()
+}
+
+// Reply to a request with an envelope containing either the result or the error.
+// The reply is encoded with the codec the request was encoded with.
+func respond[T any](msg *nats.Msg, result T, err error) {
+	if msg.Reply == "" {
+		return
+	}
+	c, codecErr := codecOf(msg)
+	if codecErr != nil {
+		c = codec.Default
+	}
+	data, encodeErr := c.Encode(reply.From(result, err))
+	if encodeErr != nil {
+		data, _ = c.Encode(reply.Fail[any](encodeErr))
 	}
-	return []byte(err.Error())
+	response := nats.NewMsg(msg.Reply)
+	response.Header.Set(codec.Header, c.ContentType())
+	response.Data = data
+	msg.RespondMsg(response)
+	// Clearing the reply subject marks the request as answered, so it is not answered again
+	msg.Reply = ""
 }
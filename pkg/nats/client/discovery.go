@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+)
+
+// Replies to a Describe request are collected for this long
+const describeWindow = 100 * time.Millisecond
+
+// The version of the running executable
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			v += " " + s.Value
+		}
+	}
+	return v
+}
+
+// Answer Describe requests with the subjects this client handles.
+// Every client answers, so it is not a queue subscription.
+func (client Subscriber) describe() error {
+	sub, err := client.nc.Subscribe(discovery.Describe, client.tracker.wrap(func(msg *nats.Msg) {
+		service := discovery.Service{
+			Name:     client.nc.Opts.Name,
+			Instance: client.namespace.name,
+			Version:  version(),
+			Subjects: client.tracker.subjects(),
+		}
+		respond(msg, service, nil)
+	}))
+	if err == nil {
+		client.tracker.track(sub)
+	}
+	return err
+}
+
+// Ask every connected client to describe itself
+func (client Requester) Describe(ctx context.Context) ([]discovery.Service, error) {
+	inbox := nats.NewInbox()
+	replies, err := client.nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, requestError(discovery.Describe, err)
+	}
+	defer replies.Unsubscribe()
+
+	msg, err := newMsg(discovery.Describe, client.codec, api.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	msg.Reply = inbox
+	if err := client.nc.PublishMsg(msg); err != nil {
+		return nil, requestError(discovery.Describe, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, describeWindow)
+	defer cancel()
+	var result []discovery.Service
+	for {
+		response, err := replies.NextMsgWithContext(ctx)
+		if err != nil {
+			// The window closed
+			return result, nil
+		}
+		if service, err := decodeReply[discovery.Service](discovery.Describe, response); err == nil {
+			result = append(result, service)
+		}
+	}
+}
+
+func (client Requester) Discover(ctx context.Context) ([]discovery.Service, error) {
+	return Request(ctx, client, discovery.DiscoverEndpoint, api.Empty{})
+}
+
+func (client Subscriber) Discover(callback func(context.Context) ([]discovery.Service, error)) (*Subscription, error) {
+	return HandleContext(client, discovery.DiscoverEndpoint, func(ctx context.Context, _ api.Empty) ([]discovery.Service, error) {
+		return callback(ctx)
+	})
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/health"
+)
+
+func TestDiscovery(t *testing.T) {
+	s := runServer(t)
+	driver, err := New(s.ClientURL(), time.Second, nats.Name("driver"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	Handle(*driver.Subscribe, double, func(i int) (int, error) { return i * 2, nil })
+	Listen(*driver.Subscribe, event, func(string) {})
+	driver.nc.Flush()
+
+	shell := connect(t, s)
+	shell.Subscribe.Discover(shell.Request.Describe)
+	shell.nc.Flush()
+
+	c := connect(t, s)
+	services, err := c.Request.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("Expected every client to describe itself, got %+v", services)
+	}
+	if !discovery.Handled(services, double.Subject) || discovery.Handled(services, "Window.Maximize") {
+		t.Fatalf("Expected only %s to be handled, got %+v", double.Subject, services)
+	}
+	for _, service := range services {
+		if service.Name != "driver" {
+			continue
+		}
+		// Every client answers pings
+		if len(service.Subjects) != 3 || service.Subjects[0].Subject != health.Ping {
+			t.Fatalf("Expected 3 subjects, got %+v", service.Subjects)
+		}
+		req := service.Subjects[1]
+		if req.Kind != discovery.Request || req.Request.Type != "integer" || req.Response.Type != "integer" {
+			t.Fatalf("Expected a request from integer to integer, got %+v", req)
+		}
+		if evt := service.Subjects[2]; evt.Kind != discovery.Event || evt.Request.Type != "string" {
+			t.Fatalf("Expected a string event, got %+v", evt)
+		}
+		return
+	}
+	t.Fatalf("Expected the driver to be discovered, got %+v", services)
+}
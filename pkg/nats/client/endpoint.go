@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+)
+
+// Send a request to an endpoint and wait for the reply.
+// The request is abandoned when ctx is done. If ctx has no deadline, the timeout of the client applies.
+func Request[Req, Resp any](ctx context.Context, client Requester, endpoint api.Endpoint[Req, Resp], payload Req) (Resp, error) {
+	return request[Resp](ctx, client, endpoint.Subject, payload)
+}
+
+// Answer requests to an endpoint. The callback has no context, so the messages it sends start a new trace.
+// Handlers which send messages use HandleContext instead.
+func Handle[Req, Resp any](client Subscriber, endpoint api.Endpoint[Req, Resp], callback func(Req) (Resp, error)) (*Subscription, error) {
+	return HandleContext(client, endpoint, func(_ context.Context, r Req) (Resp, error) {
+		return callback(r)
+	})
+}
+
+// Answer requests to an endpoint. Requests sent with ctx belong to the trace of the request being answered.
+func HandleContext[Req, Resp any](client Subscriber, endpoint api.Endpoint[Req, Resp], callback func(context.Context, Req) (Resp, error)) (*Subscription, error) {
+	desc := discovery.Subject{
+		Subject:  endpoint.Subject,
+		Kind:     discovery.Request,
+		Request:  discovery.SchemaOf[Req](),
+		Response: discovery.SchemaOf[Resp](),
+	}
+	return client.handle(desc, answer(callback))
+}
+
+// Answer requests to an endpoint from every subscriber, instead of from a single member of the queue group.
+// Used for endpoints which are notified, and where each subscriber may reply.
+func HandleEach[Req, Resp any](client Subscriber, endpoint api.Endpoint[Req, Resp], callback func(Req) (Resp, error)) (*Subscription, error) {
+	desc := discovery.Subject{
+		Subject:  endpoint.Subject,
+		Kind:     discovery.Request,
+		Request:  discovery.SchemaOf[Req](),
+		Response: discovery.SchemaOf[Resp](),
+	}
+	return client.listen(desc, answer(func(_ context.Context, r Req) (Resp, error) {
+		return callback(r)
+	}))
+}
+
+func answer[Req, Resp any](callback func(context.Context, Req) (Resp, error)) Handler {
+	return func(ctx context.Context, msg *nats.Msg) error {
+		payload, err := decode[Req](msg)
+		if err != nil {
+			return err
+		}
+		result, err := callback(ctx, payload)
+		respond(msg, result, err)
+		return err
+	}
+}
+
+// Send a request to an endpoint without waiting for the reply
+func Notify[Req, Resp any](client Publisher, endpoint api.Endpoint[Req, Resp], payload Req) error {
+	return client.publish(endpoint.Subject, payload)
+}
+
+func Publish[T any](client Publisher, event api.Event[T], payload T) error {
+	return client.publish(event.Subject, payload)
+}
+
+// Listen to an event. The callback has no context, so the messages it sends start a new trace.
+// Listeners which send messages use ListenContext instead.
+func Listen[T any](client Subscriber, event api.Event[T], callback func(T)) (*Subscription, error) {
+	return ListenContext(client, event, func(_ context.Context, t T) { callback(t) })
+}
+
+// Listen to an event. Messages sent with ctx belong to the trace of the event.
+func ListenContext[T any](client Subscriber, event api.Event[T], callback func(context.Context, T)) (*Subscription, error) {
+	desc := discovery.Subject{
+		Subject: event.Subject,
+		Kind:    discovery.Event,
+		Request: discovery.SchemaOf[T](),
+	}
+	return client.listen(desc, func(ctx context.Context, msg *nats.Msg) error {
+		payload, err := decode[T](msg)
+		if err == nil {
+			callback(ctx, payload)
+		}
+		return err
+	})
+}
+
+// Adapt a callback which cannot fail
+func infallible[Req, Resp any](callback func(Req) Resp) func(Req) (Resp, error) {
+	return func(r Req) (Resp, error) {
+		return callback(r), nil
+	}
+}
+
+// Adapt a callback which takes no arguments and cannot fail
+func noArguments[Resp any](callback func() Resp) func(api.Empty) (Resp, error) {
+	return func(api.Empty) (Resp, error) {
+		return callback(), nil
+	}
+}
+
+// Adapt a callback which only reports errors
+func noResult[Req any](callback func(Req) error) func(Req) (api.Empty, error) {
+	return func(r Req) (api.Empty, error) {
+		return api.Empty{}, callback(r)
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/codec"
+)
+
+func runServer(t *testing.T) *server.Server {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	s := natsserver.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func connect(t *testing.T, s *server.Server) Client {
+	c, err := New(s.ClientURL(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+var (
+	double = api.Endpoint[int, int]{Subject: "Test.Double"}
+	event  = api.Event[string]{Subject: "Test.Event"}
+)
+
+func TestEndpointRoundTrip(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+
+	Handle(*c.Subscribe, double, func(i int) (int, error) {
+		if i < 0 {
+			return 0, reply.Errorf(reply.InvalidRequest, "%d is negative", i)
+		}
+		return i * 2, nil
+	})
+	c.nc.Flush()
+
+	for _, cod := range []codec.Codec{codec.YAML, codec.JSON, codec.MsgPack} {
+		r := c.Request.WithCodec(cod)
+		result, err := Request(context.Background(), r, double, 21)
+		if err != nil || result != 42 {
+			t.Fatalf("%s: Expected 42, got %v (%v)", cod.ContentType(), result, err)
+		}
+		_, err = Request(context.Background(), r, double, -1)
+		if !reply.IsCode(err, reply.InvalidRequest) {
+			t.Fatalf("%s: Expected %s, got %v", cod.ContentType(), reply.InvalidRequest, err)
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+
+	_, err := Request(context.Background(), *c.Request, double, 1)
+	if !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected %s, got %v", reply.NoResponders, err)
+	}
+
+	c.nc.Subscribe(double.Subject, func(msg *nats.Msg) {})
+	c.nc.Flush()
+	r := *c.Request
+	r.timeout = time.Millisecond * 10
+	_, err = Request(context.Background(), r, double, 1)
+	if !reply.IsCode(err, reply.Timeout) {
+		t.Fatalf("Expected %s, got %v", reply.Timeout, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = Request(ctx, *c.Request, double, 1)
+	if !reply.IsCode(err, reply.Timeout) {
+		t.Fatalf("Expected %s, got %v", reply.Timeout, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*10, cancel)
+	_, err = Request(ctx, *c.Request, double, 1)
+	if !reply.IsCode(err, reply.Canceled) {
+		t.Fatalf("Expected %s, got %v", reply.Canceled, err)
+	}
+}
+
+func TestMalformedPayload(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	Handle(*c.Subscribe, double, func(i int) (int, error) { return i * 2, nil })
+	c.nc.Flush()
+
+	_, err := request[int](context.Background(), *c.Request, double.Subject, "not a number")
+	if !reply.IsCode(err, reply.InvalidRequest) {
+		t.Fatalf("Expected %s, got %v", reply.InvalidRequest, err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+
+	received := make(chan string, 1)
+	Listen(*c.Subscribe, event, func(s string) { received <- s })
+	c.nc.Flush()
+
+	Publish(c.Publish.WithCodec(codec.MsgPack), event, "hello")
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Fatalf("Expected 'hello', got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No event received")
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/health"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/codec"
+)
+
+func (client Requester) Ping(ctx context.Context) (string, error) {
+	return Request(ctx, client, health.PingEndpoint, api.Empty{})
+}
+
+func (client Subscriber) Ping(callback func() string) (*Subscription, error) {
+	return Handle(client, health.PingEndpoint, noArguments(callback))
+}
+
+// Run the health check of a service once. The request is sent to the instance of the service,
+// and checks with an expected result fail if the result is different.
+//
+// Without a subject, the instance of the service is pinged. Every handler has its own subscription,
+// so a ping only detects that the service is connected and still handling messages. A handler which
+// is stuck does not hold up pings; check the subject of the handler to detect that. The configuration
+// requires a subject for services which restart when they are unhealthy.
+func HealthCheck(ctx context.Context, client Requester, service string, check shell.HealthCheck) error {
+	if check.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Millisecond*time.Duration(check.Timeout))
+		defer cancel()
+	}
+	client = client.Instance(service)
+	if check.Subject == "" {
+		_, err := client.Ping(ctx)
+		return err
+	}
+	endpoint := api.Endpoint[any, any]{Subject: check.Subject}
+	result, err := Request(ctx, client, endpoint, check.Payload)
+	if err != nil || check.Expect == nil {
+		return err
+	}
+	// Compare the encoded values, since the result and the expectation may be decoded into different types
+	expected, _ := codec.YAML.Encode(check.Expect)
+	actual, _ := codec.YAML.Encode(result)
+	if !bytes.Equal(expected, actual) {
+		return reply.Errorf(reply.Failed, "%s: expected %s, got %s", check.Subject, bytes.TrimSpace(expected), bytes.TrimSpace(actual))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+var wedged = api.Endpoint[int, int]{Subject: "Test.Wedged"}
+
+func TestHealthCheck(t *testing.T) {
+	s := runServer(t)
+	driver, err := dial(s.ClientURL(), time.Second, shell.NatsConfig{Namespace: "driver"}, nats.Name("driver"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	Handle(*driver.Subscribe, double, func(i int) (int, error) { return i * 2, nil })
+	driver.nc.Flush()
+	c := connect(t, s)
+	ctx := context.Background()
+
+	if err := HealthCheck(ctx, *c.Request, "driver", shell.HealthCheck{}); err != nil {
+		t.Fatalf("Expected driver to answer pings, got %v", err)
+	}
+	if err := HealthCheck(ctx, *c.Request, "hotkeys", shell.HealthCheck{}); !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected hotkeys to not answer pings, got %v", err)
+	}
+
+	check := shell.HealthCheck{Subject: double.Subject, Payload: 2, Expect: 4}
+	if err := HealthCheck(ctx, *c.Request, "driver", check); err != nil {
+		t.Fatalf("Expected a healthy reply, got %v", err)
+	}
+	check.Expect = 5
+	if err := HealthCheck(ctx, *c.Request, "driver", check); !reply.IsCode(err, reply.Failed) {
+		t.Fatalf("Expected an unexpected reply, got %v", err)
+	}
+	// The check is sent to the instance of the service, even though driver answers the global subject
+	if err := HealthCheck(ctx, *c.Request, "hotkeys", check); !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected hotkeys to not answer %s, got %v", check.Subject, err)
+	}
+
+	// A wedged handler times out
+	Handle(*driver.Subscribe, wedged, func(int) (int, error) { time.Sleep(time.Millisecond * 100); return 0, nil })
+	driver.nc.Flush()
+	check = shell.HealthCheck{Subject: wedged.Subject, Payload: 1, Timeout: 10}
+	if err := HealthCheck(ctx, *c.Request, "driver", check); !reply.IsCode(err, reply.Timeout) {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+}
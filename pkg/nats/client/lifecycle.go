@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+// Keeps track of the subscriptions created by a client, and of the handlers currently running
+type tracker struct {
+	lock      sync.Mutex
+	subs      []*nats.Subscription
+	described []described
+	closed    bool
+	// Held for reading by running handlers, and for writing while the client is closing
+	inflight sync.RWMutex
+}
+
+type described struct {
+	sub     *nats.Subscription
+	subject discovery.Subject
+}
+
+func (t *tracker) track(sub *nats.Subscription) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.subs = append(t.subs, sub)
+}
+
+// Advertise the subject of a subscription for as long as it is active
+func (t *tracker) describe(sub *nats.Subscription, subject discovery.Subject) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.described = append(t.described, described{sub, subject})
+}
+
+// Get the subjects of the active subscriptions
+func (t *tracker) subjects() []discovery.Subject {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var result []discovery.Subject
+	for _, d := range t.described {
+		if d.sub.IsValid() {
+			result = append(result, d.subject)
+		}
+	}
+	return result
+}
+
+// Wrap a handler so closing the client waits for it to return.
+// Messages which are delivered after the client is closed are dropped.
+func (t *tracker) wrap(handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		t.inflight.RLock()
+		defer t.inflight.RUnlock()
+		if t.closed {
+			return
+		}
+		handler(msg)
+	}
+}
+
+// Unsubscribe everything and wait for running handlers to return
+func (t *tracker) close() {
+	t.lock.Lock()
+	subs := t.subs
+	t.subs = nil
+	t.lock.Unlock()
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+	t.inflight.Lock()
+	t.closed = true
+	t.inflight.Unlock()
+}
+
+// Get the subscriptions created by the client which are still active
+func (client Client) Subscriptions() []*nats.Subscription {
+	t := client.Subscribe.tracker
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var result []*nats.Subscription
+	for _, s := range t.subs {
+		if s.IsValid() {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Unsubscribe from everything, wait for running handlers to return, and close the connection.
+// Close must not be called from a handler.
+func (client Client) Close() {
+	client.Subscribe.tracker.close()
+	client.nc.Close()
+}
+
+// Stop receiving messages, finish the messages which were already received, flush pending
+// publishes, and close the connection. Drain must not be called from a handler.
+func (client Client) Drain() error {
+	if err := client.nc.Drain(); err != nil {
+		return err
+	}
+	<-client.closed
+	client.Subscribe.tracker.close()
+	return nil
+}
+
+// Tell the shell that the service this client is named after is ready, so services which depend on it can start
+func (client Client) Ready() error {
+	if err := client.Publish.ServiceReady(client.nc.Opts.Name); err != nil {
+		return err
+	}
+	return client.nc.Flush()
+}
+
+type shutdown struct {
+	lock     sync.Mutex
+	handlers []func()
+}
+
+// Register a handler which runs when Run returns, before the client is drained.
+// Handlers run in the reverse order they were registered in.
+func (client Client) OnShutdown(handler func()) {
+	client.shutdown.lock.Lock()
+	defer client.shutdown.lock.Unlock()
+	client.shutdown.handlers = append(client.shutdown.handlers, handler)
+}
+
+func (s *shutdown) run() {
+	s.lock.Lock()
+	handlers := s.handlers
+	s.handlers = nil
+	s.lock.Unlock()
+	for i := len(handlers) - 1; i >= 0; i-- {
+		handlers[i]()
+	}
+}
+
+// Signal readiness, and block until ctx is done, the process is interrupted, or the shell asks the
+// service this client is named after to shut down. Shutdown handlers run, and the client is drained before returning.
+func (client Client) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	name := client.Subscribe.namespace.name
+	if name == "" {
+		name = client.nc.Opts.Name
+	}
+	if name != "" {
+		// Only answer shutdown requests to this instance. The request is acknowledged
+		// immediately, and the shell waits for the process to exit.
+		sub, err := client.nc.Subscribe(qualify(name, shell.Shutdown), client.Subscribe.tracker.wrap(func(msg *nats.Msg) {
+			respond(msg, api.Empty{}, nil)
+			stop()
+		}))
+		if err != nil {
+			return err
+		}
+		client.Subscribe.tracker.track(sub)
+	}
+	if client.nc.Opts.Name != "" {
+		if err := client.Ready(); err != nil {
+			return err
+		}
+	}
+
+	<-ctx.Done()
+	client.shutdown.run()
+	return client.Drain()
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+func TestCloseWaitsForHandlers(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	r := connect(t, s)
+
+	// Every client answers discovery requests
+	base := len(c.Subscriptions())
+	started := make(chan bool)
+	finished := false
+	Listen(*c.Subscribe, event, func(string) {
+		started <- true
+		time.Sleep(time.Millisecond * 50)
+		finished = true
+	})
+	Handle(*c.Subscribe, double, func(i int) (int, error) { return i * 2, nil })
+	if n := len(c.Subscriptions()) - base; n != 2 {
+		t.Fatalf("Expected 2 subscriptions, got %d", n)
+	}
+	c.nc.Flush()
+
+	Publish(*r.Publish, event, "hello")
+	<-started
+	c.Close()
+	if !finished {
+		t.Fatalf("Expected the handler to finish before Close returned")
+	}
+	if n := len(c.Subscriptions()); n != 0 {
+		t.Fatalf("Expected 0 subscriptions, got %d", n)
+	}
+}
+
+func TestRunStopsOnShutdown(t *testing.T) {
+	s := runServer(t)
+	c, err := New(s.ClientURL(), time.Second, nats.Name("driver"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := connect(t, s)
+	ready := make(chan string, 1)
+	r.Subscribe.ServiceReady(func(s string) { ready <- s })
+	r.nc.Flush()
+
+	var order []int
+	c.OnShutdown(func() { order = append(order, 1) })
+	c.OnShutdown(func() { order = append(order, 2) })
+
+	done := make(chan error)
+	go func() { done <- c.Run(context.Background()) }()
+
+	// Run signals readiness once it is subscribed
+	if name := <-ready; name != "driver" {
+		t.Fatalf("Expected driver to be ready, got %s", name)
+	}
+	ctx := context.Background()
+	if err := r.Request.Instance("events").Shutdown(ctx); !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected only driver to answer, got %v", err)
+	}
+	if err := r.Request.Instance("driver").Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Run to return after %s", shell.Shutdown)
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Fatalf("Expected shutdown handlers to run in reverse order, got %v", order)
+	}
+	if !c.nc.IsClosed() {
+		t.Fatalf("Expected the connection to be closed")
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+)
+
+// Convert a line published by the shell to an entry.
+// Lines which were published without headers are stamped with the time they were received.
+func LogEntry(msg *nats.Msg) (logs.Entry, bool) {
+	stream, service, ok := strings.Cut(msg.Subject, ".")
+	if !ok || (stream != logs.Stdout && stream != logs.Stderr) {
+		return logs.Entry{}, false
+	}
+	e := logs.Entry{Service: service, Stream: stream, Time: time.Now(), Line: string(msg.Data)}
+	if msg.Header != nil {
+		if t, err := time.Parse(time.RFC3339Nano, msg.Header.Get(logs.TimestampHeader)); err == nil {
+			e.Time = t
+		}
+		e.Sequence, _ = strconv.Atoi(msg.Header.Get(logs.SequenceHeader))
+		e.Truncated = msg.Header.Get(logs.TruncatedHeader) != ""
+	}
+	return e, true
+}
+
+// Receive every line a service writes to a stream. If service is empty, lines of every service are received.
+func (client Subscriber) Output(stream, service string, callback func(logs.Entry)) (*Subscription, error) {
+	if service == "" {
+		service = ">"
+	}
+	desc := discovery.Subject{Subject: logs.Subject(stream, service), Kind: discovery.Event}
+	return client.listen(desc, func(_ context.Context, msg *nats.Msg) error {
+		if e, ok := LogEntry(msg); ok {
+			callback(e)
+		}
+		return nil
+	})
+}
+
+func (client Requester) QueryLogs(ctx context.Context, filter logs.Filter) ([]logs.Entry, error) {
+	return Request(ctx, client, logs.QueryEndpoint, filter)
+}
+
+func (client Subscriber) QueryLogs(callback func(logs.Filter) ([]logs.Entry, error)) (*Subscription, error) {
+	return Handle(client, logs.QueryEndpoint, callback)
+}
+
+// Receive the recorded entries which match filter, and then every new matching entry until ctx is done.
+// If the log service is not running, only new entries are received.
+func (client Client) FollowLogs(ctx context.Context, filter logs.Filter, callback func(logs.Entry)) error {
+	match, err := filter.Matcher()
+	if err != nil {
+		return reply.Errorf(reply.InvalidRequest, "%v", err)
+	}
+	live := make(chan logs.Entry, 100)
+	for _, stream := range []string{logs.Stdout, logs.Stderr} {
+		if filter.Stream != "" && filter.Stream != stream {
+			continue
+		}
+		sub, err := client.Subscribe.Output(stream, filter.Service, func(e logs.Entry) {
+			if match(e) {
+				select {
+				case live <- e:
+				case <-ctx.Done():
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		defer sub.Unsubscribe()
+	}
+	// Subscribe before querying, so no entries are missed in between
+	if err := client.nc.Flush(); err != nil {
+		return err
+	}
+
+	history, err := client.Request.QueryLogs(ctx, filter)
+	if err != nil && !reply.IsCode(err, reply.NoResponders) {
+		return err
+	}
+	// Entries which were received live while the query was answered are skipped
+	latest := map[string]time.Time{}
+	for _, e := range history {
+		latest[e.Stream+"."+e.Service] = e.Time
+		callback(e)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-live:
+			if t, ok := latest[e.Stream+"."+e.Service]; ok && !e.Time.After(t) {
+				continue
+			}
+			callback(e)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/logs"
+)
+
+func TestFollowLogs(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	r := connect(t, s)
+
+	line := func(service, text string, at time.Time) *nats.Msg {
+		msg := nats.NewMsg(logs.Subject(logs.Stdout, service))
+		msg.Data = []byte(text)
+		msg.Header.Set(logs.TimestampHeader, at.Format(time.RFC3339Nano))
+		return msg
+	}
+	start := time.Now()
+	// The log service publishes a line which was already recorded while it answers the query
+	r.Subscribe.QueryLogs(func(f logs.Filter) ([]logs.Entry, error) {
+		r.nc.PublishMsg(line("driver", "recorded", start))
+		r.nc.Flush()
+		time.Sleep(time.Millisecond * 20)
+		return []logs.Entry{{Service: "driver", Stream: logs.Stdout, Time: start, Line: "recorded"}}, nil
+	})
+	r.nc.Flush()
+
+	received := make(chan logs.Entry, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.FollowLogs(ctx, logs.Filter{Service: "driver"}, func(e logs.Entry) { received <- e })
+	}()
+
+	expect := func(text string) {
+		t.Helper()
+		select {
+		case e := <-received:
+			if e.Line != text || e.Service != "driver" || e.Stream != logs.Stdout {
+				t.Fatalf("Expected '%s', got %+v", text, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected '%s'", text)
+		}
+	}
+	expect("recorded")
+	r.nc.PublishMsg(line("events", "other service", start.Add(time.Millisecond)))
+	r.nc.PublishMsg(line("driver", "live", start.Add(time.Millisecond)))
+	expect("live")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-received:
+		t.Fatalf("Expected no more entries, got %+v", e)
+	default:
+	}
+}
+
+func TestLogEntry(t *testing.T) {
+	msg := nats.NewMsg(logs.Subject(logs.Stderr, "hotkeys"))
+	msg.Data = []byte("panic")
+	msg.Header.Set(logs.SequenceHeader, "3")
+	msg.Header.Set(logs.TruncatedHeader, "true")
+	e, ok := LogEntry(msg)
+	if !ok || e.Service != "hotkeys" || e.Stream != logs.Stderr || e.Sequence != 3 || !e.Truncated || e.Time.IsZero() {
+		t.Fatalf("Unexpected entry %+v", e)
+	}
+	if _, ok := LogEntry(nats.NewMsg("Shell.Config")); ok {
+		t.Fatalf("Expected Shell.Config not to be a log entry")
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/health"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+	"github.com/operdies/windows-nats-shell/pkg/nats/codec"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
+)
+
+// Handler handles a raw message. It returns the error the message was answered with, if any.
+// Messages sent with ctx belong to the span of the handled message.
+type Handler func(ctx context.Context, msg *nats.Msg) error
+
+// Middleware wraps the handler of every subscription created by a subscriber
+type Middleware func(next Handler) Handler
+
+// The middleware of a subscriber, which is shared with its copies
+type middlewares struct {
+	lock sync.Mutex
+	list []Middleware
+}
+
+// Add middleware to every subscription created by this subscriber from now on.
+// The middleware added first sees messages first.
+func (client Subscriber) Use(middleware ...Middleware) {
+	client.middleware.lock.Lock()
+	defer client.middleware.lock.Unlock()
+	client.middleware.list = append(client.middleware.list, middleware...)
+}
+
+func (client Subscriber) chain(handler Handler) nats.MsgHandler {
+	client.middleware.lock.Lock()
+	list := client.middleware.list
+	client.middleware.lock.Unlock()
+	for i := len(list) - 1; i >= 0; i-- {
+		handler = list[i](handler)
+	}
+	return func(msg *nats.Msg) {
+		sc, parent := trace.Extract(msg.Header)
+		start := time.Now()
+		err := handler(trace.WithSpan(context.Background(), sc), msg)
+		// Spans are not recorded for recording spans, or for health checks which would drown out everything else
+		if client.record && msg.Subject != shell.TraceSpan && !strings.HasSuffix(msg.Subject, health.Ping) {
+			span := trace.Span{
+				Trace:    sc.Trace,
+				Id:       sc.Span,
+				Parent:   parent,
+				Subject:  msg.Subject,
+				Service:  client.service,
+				Start:    start,
+				Duration: time.Since(start),
+				Code:     reply.CodeOf(err),
+			}
+			if err != nil {
+				span.Message = err.Error()
+			}
+			publisher := Publisher{nc: client.nc, codec: codec.Default}
+			Publish(publisher, shell.TraceSpans, span)
+		}
+	}
+}
+
+// Recover from panics in handlers. Requests which were not answered before the panic are answered with a Failed error.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *nats.Msg) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("panic in handler of %s: %v\n%s", msg.Subject, r, debug.Stack())
+					err = reply.Errorf(reply.Failed, "%s: %v", msg.Subject, r)
+					// The reply subject is cleared once a request is answered
+					if msg.Reply != "" {
+						respond[any](msg, nil, err)
+					}
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Log every message and its outcome to logger, or to the standard logger if logger is nil
+func Logger(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			line := fmt.Sprintf("subject=%s duration=%v", msg.Subject, time.Since(start))
+			if err != nil {
+				line += fmt.Sprintf(" code=%s error=%q", reply.CodeOf(err), err.Error())
+			}
+			logger.Println(line)
+			return err
+		}
+	}
+}
+
+// Report how long every message took to handle
+func Latency(observe func(subject string, elapsed time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			observe(msg.Subject, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Only handle messages which allow accepts. Rejected requests are answered with the
+// error returned by allow, or an Unauthorized error if it is not a reply.Error.
+func Authorize(allow func(msg *nats.Msg) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			if err := allow(msg); err != nil {
+				var e *reply.Error
+				if !errors.As(err, &e) {
+					err = reply.Errorf(reply.Unauthorized, "%s: %v", msg.Subject, err)
+				}
+				respond[any](msg, nil, err)
+				return err
+			}
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+)
+
+func TestMiddleware(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, msg *nats.Msg) error {
+				order = append(order, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+	observed := make(chan error, 1)
+	c.Subscribe.Use(trace("first"), trace("second"), Latency(func(_ string, _ time.Duration, err error) {
+		observed <- err
+	}), Recover())
+	c.Subscribe.Use(Authorize(func(msg *nats.Msg) error {
+		if msg.Header.Get("Token") != "secret" {
+			return errors.New("bad token")
+		}
+		return nil
+	}))
+	Handle(*c.Subscribe, double, func(i int) (int, error) {
+		if i == 0 {
+			panic("zero")
+		}
+		return i * 2, nil
+	})
+	c.nc.Flush()
+
+	_, err := Request(context.Background(), *c.Request, double, 1)
+	if !reply.IsCode(err, reply.Unauthorized) {
+		t.Fatalf("Expected %s, got %v", reply.Unauthorized, err)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("Expected middleware to run in order, got %v", order)
+	}
+	if err := <-observed; !reply.IsCode(err, reply.Unauthorized) {
+		t.Fatalf("Expected latency to observe %s, got %v", reply.Unauthorized, err)
+	}
+
+	send := func(i int) (int, error) {
+		msg, _ := newMsg(double.Subject, c.Request.codec, i)
+		msg.Header.Set("Token", "secret")
+		msg.Reply = nats.NewInbox()
+		sub, _ := c.nc.SubscribeSync(msg.Reply)
+		defer sub.Unsubscribe()
+		c.nc.PublishMsg(msg)
+		response, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decodeReply[int](double.Subject, response)
+	}
+	if result, err := send(2); err != nil || result != 4 {
+		t.Fatalf("Expected 4, got %v (%v)", result, err)
+	}
+	<-observed
+	if _, err := send(0); !reply.IsCode(err, reply.Failed) {
+		t.Fatalf("Expected %s, got %v", reply.Failed, err)
+	}
+	if err := <-observed; !reply.IsCode(err, reply.Failed) {
+		t.Fatalf("Expected latency to observe %s, got %v", reply.Failed, err)
+	}
+}
+
+func TestRecoverAfterReply(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	c.Subscribe.Use(Recover())
+	c.Subscribe.handle(discovery.Subject{Subject: "Test.PanicAfterReply"}, func(_ context.Context, msg *nats.Msg) error {
+		respond(msg, 1, nil)
+		panic("after reply")
+	})
+	c.nc.Flush()
+
+	inbox, _ := c.nc.SubscribeSync(nats.NewInbox())
+	c.nc.PublishRequest("Test.PanicAfterReply", inbox.Subject, nil)
+	response, err := inbox.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := decodeReply[int]("Test.PanicAfterReply", response); err != nil || result != 1 {
+		t.Fatalf("Expected 1, got %v (%v)", result, err)
+	}
+	if _, err := inbox.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Expected a single reply")
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/discovery"
+)
+
+// Handlers of the same request subject join this queue group, so requests
+// which do not target a specific instance are answered exactly once.
+const queueGroup = "handlers"
+
+// Get the subject of an instance. An empty instance refers to any instance.
+func qualify(instance, subject string) string {
+	if instance == "" {
+		return subject
+	}
+	return instance + "." + subject
+}
+
+// Get a requester which only targets the given instance of a service.
+// An empty instance targets any instance.
+func (client Requester) Instance(name string) Requester {
+	client.instance = name
+	return client
+}
+
+// Get a publisher which only targets the given instance of a service.
+// An empty instance targets any instance.
+func (client Publisher) Instance(name string) Publisher {
+	client.instance = name
+	return client
+}
+
+// A subscription to a subject, and to the same subject in the namespace of the client.
+// Unsubscribing or draining it stops both.
+type Subscription struct {
+	*nats.Subscription
+	namespaced *nats.Subscription
+}
+
+func (s *Subscription) Unsubscribe() error {
+	if s.namespaced != nil {
+		s.namespaced.Unsubscribe()
+	}
+	return s.Subscription.Unsubscribe()
+}
+
+func (s *Subscription) Drain() error {
+	if s.namespaced != nil {
+		s.namespaced.Drain()
+	}
+	return s.Subscription.Drain()
+}
+
+// A namespace holds the instance name which qualifies the subjects of a subscriber
+type namespace struct {
+	name string
+}
+
+func newNamespace() *namespace {
+	return &namespace{}
+}
+
+// Subscribe the handler to the subject in the namespace. Each handler has its own subscription,
+// so a slow handler does not hold up the other subjects of the namespace.
+func (n *namespace) add(nc *nats.Conn, t *tracker, subject string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	if n.name == "" {
+		return nil, nil
+	}
+	sub, err := nc.Subscribe(qualify(n.name, subject), handler)
+	if err != nil {
+		return nil, err
+	}
+	t.track(sub)
+	return sub, nil
+}
+
+// Subscribe to a request subject, and to the same subject in the namespace of this client.
+func (client Subscriber) handle(desc discovery.Subject, h Handler) (*Subscription, error) {
+	subject := desc.Subject
+	handler := client.tracker.wrap(client.chain(h))
+	sub, err := client.nc.QueueSubscribe(subject, queueGroup, handler)
+	if err != nil {
+		return nil, err
+	}
+	namespaced, err := client.namespace.add(client.nc, client.tracker, subject, handler)
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	client.tracker.track(sub)
+	client.tracker.describe(sub, desc)
+	return &Subscription{sub, namespaced}, nil
+}
+
+// Subscribe to an event subject, and to the same subject in the namespace of this client.
+// Every listener receives every event.
+func (client Subscriber) listen(desc discovery.Subject, h Handler) (*Subscription, error) {
+	subject := desc.Subject
+	handler := client.tracker.wrap(client.chain(h))
+	sub, err := client.nc.Subscribe(subject, handler)
+	if err != nil {
+		return nil, err
+	}
+	namespaced, err := client.namespace.add(client.nc, client.tracker, subject, handler)
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	client.tracker.track(sub)
+	client.tracker.describe(sub, desc)
+	return &Subscription{sub, namespaced}, nil
+}
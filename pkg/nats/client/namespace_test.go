@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+)
+
+var whoami = api.Endpoint[api.Empty, string]{Subject: "Test.WhoAmI"}
+
+func instance(t *testing.T, url, name string) Client {
+	c, err := New(url, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Subscribe.namespace.name = name
+	t.Cleanup(c.Close)
+	Handle(*c.Subscribe, whoami, func(api.Empty) (string, error) { return name, nil })
+	c.nc.Flush()
+	return c
+}
+
+func TestNamespaces(t *testing.T) {
+	s := runServer(t)
+	instance(t, s.ClientURL(), "a")
+	instance(t, s.ClientURL(), "b")
+	c := connect(t, s)
+
+	for _, name := range []string{"a", "b"} {
+		got, err := Request(context.Background(), c.Request.Instance(name), whoami, api.Empty{})
+		if err != nil || got != name {
+			t.Fatalf("Expected %s, got %v (%v)", name, got, err)
+		}
+	}
+
+	_, err := Request(context.Background(), c.Request.Instance("c"), whoami, api.Empty{})
+	if !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected %s, got %v", reply.NoResponders, err)
+	}
+
+	// Requests to any instance are answered exactly once
+	sub, _ := c.nc.SubscribeSync(nats.NewInbox())
+	for i := 0; i < 10; i++ {
+		c.nc.PublishRequest(whoami.Subject, sub.Subject, nil)
+	}
+	c.nc.Flush()
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[string(msg.Data)] = true
+	}
+	if _, err := sub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Expected exactly one reply per request")
+	}
+	if len(seen) == 0 {
+		t.Fatalf("Expected replies")
+	}
+}
+
+func TestSlowHandlerDoesNotBlockNamespace(t *testing.T) {
+	s := runServer(t)
+	c := instance(t, s.ClientURL(), "a")
+	slow := api.Endpoint[api.Empty, api.Empty]{Subject: "Test.Slow"}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	Handle(*c.Subscribe, slow, func(api.Empty) (api.Empty, error) {
+		close(started)
+		<-release
+		return api.Empty{}, nil
+	})
+	c.nc.Flush()
+
+	requester := connect(t, s).Request.Instance("a")
+	go Request(context.Background(), requester, slow, api.Empty{})
+	<-started
+	got, err := Request(context.Background(), requester, whoami, api.Empty{})
+	if err != nil || got != "a" {
+		t.Fatalf("Expected a, got %v (%v)", got, err)
+	}
+}
+
+func TestUnsubscribeNamespace(t *testing.T) {
+	s := runServer(t)
+	c := instance(t, s.ClientURL(), "a")
+	gone := api.Endpoint[api.Empty, api.Empty]{Subject: "Test.Gone"}
+	before := c.nc.NumSubscriptions()
+	sub, err := Handle(*c.Subscribe, gone, func(api.Empty) (api.Empty, error) { return api.Empty{}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Unsubscribe()
+	c.nc.Flush()
+
+	if n := c.nc.NumSubscriptions(); n != before {
+		t.Fatalf("Expected %d subscriptions, got %d", before, n)
+	}
+	_, err = Request(context.Background(), connect(t, s).Request.Instance("a"), gone, api.Empty{})
+	if !reply.IsCode(err, reply.NoResponders) {
+		t.Fatalf("Expected %s, got %v", reply.NoResponders, err)
+	}
+}
@@ -1,32 +1,24 @@
 package client
 
 import (
-	"github.com/nats-io/nats.go"
+	"context"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/screen"
-	"github.com/operdies/windows-nats-shell/pkg/utils"
 )
 
-func (client Requester) GetResolution() screen.Resolution {
-	response, _ := client.nc.Request(screen.GetResolution, nil, client.timeout)
-	return utils.DecodeAny[screen.Resolution](response.Data)
+func (client Requester) GetResolution(ctx context.Context) (screen.Resolution, error) {
+	return Request(ctx, client, screen.GetResolutionEndpoint, api.Empty{})
 }
 
-func (client Subscriber) GetResolution(callback func() screen.Resolution) (*nats.Subscription, error) {
-	return client.nc.Subscribe(screen.GetResolution, func(msg *nats.Msg) {
-		resolution := callback()
-		msg.Respond(utils.EncodeAny(resolution))
-	})
+func (client Subscriber) GetResolution(callback func() screen.Resolution) (*Subscription, error) {
+	return Handle(client, screen.GetResolutionEndpoint, noArguments(callback))
 }
 
-func (client Requester) SetResolution(r screen.Resolution) error {
-	response, _ := client.nc.Request(screen.SetResolution, utils.EncodeAny(r), client.timeout)
-	return utils.DecodeAny[error](response.Data)
+func (client Requester) SetResolution(ctx context.Context, r screen.Resolution) error {
+	_, err := Request(ctx, client, screen.SetResolutionEndpoint, r)
+	return err
 }
 
-func (client Subscriber) SetResolution(callback func(screen.Resolution) error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(screen.SetResolution, func(msg *nats.Msg) {
-		resolution := utils.DecodeAny[screen.Resolution](msg.Data)
-		result := callback(resolution)
-		msg.Respond(utils.EncodeAny(result))
-	})
+func (client Subscriber) SetResolution(callback func(screen.Resolution) error) (*Subscription, error) {
+	return Handle(client, screen.SetResolutionEndpoint, noResult(callback))
 }
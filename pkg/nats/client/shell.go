@@ -1,156 +1,265 @@
 package client
 
 import (
-	"fmt"
+	"context"
 	"os"
 
-	"github.com/nats-io/nats.go"
 	"github.com/operdies/windows-nats-shell/pkg/input/keyboard"
 	"github.com/operdies/windows-nats-shell/pkg/input/mouse"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
-	"github.com/operdies/windows-nats-shell/pkg/utils"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
 )
 
-func (client Subscriber) RestartService(callback func(string) error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.RestartService, func(msg *nats.Msg) {
-		err := callback(utils.DecodeAny[string](msg.Data))
-		msg.Respond(errorOrOk(err))
-	})
+func (client Subscriber) RestartService(callback func(string) error) (*Subscription, error) {
+	return Handle(client, shell.RestartServiceEndpoint, noResult(callback))
 }
-func (client Publisher) RestartService(service string) {
-	client.nc.Publish(shell.RestartService, utils.EncodeAny(service))
+
+func (client Publisher) RestartService(service string) error {
+	return Notify(client, shell.RestartServiceEndpoint, service)
 }
 
-func (client Subscriber) StopService(callback func(string) error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.StopService, func(msg *nats.Msg) {
-		err := callback(utils.DecodeAny[string](msg.Data))
-		msg.Respond(errorOrOk(err))
-	})
+func (client Requester) RestartService(ctx context.Context, service string) error {
+	_, err := Request(ctx, client, shell.RestartServiceEndpoint, service)
+	return err
 }
 
-func (client Publisher) StopService(service string) {
-	client.nc.Publish(shell.StopService, utils.EncodeAny(service))
+func (client Subscriber) StopService(callback func(string) error) (*Subscription, error) {
+	return Handle(client, shell.StopServiceEndpoint, noResult(callback))
 }
 
-func (client Subscriber) StartService(callback func(string) error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.StartService, func(msg *nats.Msg) {
-		err := callback(utils.DecodeAny[string](msg.Data))
-		msg.Respond(errorOrOk(err))
-	})
+func (client Publisher) StopService(service string) error {
+	return Notify(client, shell.StopServiceEndpoint, service)
+}
+
+func (client Requester) StopService(ctx context.Context, service string) error {
+	_, err := Request(ctx, client, shell.StopServiceEndpoint, service)
+	return err
+}
+
+func (client Subscriber) StartService(callback func(string) error) (*Subscription, error) {
+	return Handle(client, shell.StartServiceEndpoint, noResult(callback))
+}
+
+func (client Publisher) StartService(service string) error {
+	return Notify(client, shell.StartServiceEndpoint, service)
+}
+
+func (client Requester) StartService(ctx context.Context, service string) error {
+	_, err := Request(ctx, client, shell.StartServiceEndpoint, service)
+	return err
+}
+
+func (client Subscriber) RunNow(callback func(string) error) (*Subscription, error) {
+	return Handle(client, shell.RunNowEndpoint, noResult(callback))
+}
+
+func (client Publisher) RunNow(service string) error {
+	return Notify(client, shell.RunNowEndpoint, service)
+}
+
+func (client Requester) RunNow(ctx context.Context, service string) error {
+	_, err := Request(ctx, client, shell.RunNowEndpoint, service)
+	return err
+}
+
+func (client Publisher) ServiceCompleted(run shell.ServiceRun) error {
+	return Publish(client, shell.ServiceCompletedEvent, run)
 }
 
-func (client Publisher) StartService(service string) {
-	client.nc.Publish(shell.StartService, utils.EncodeAny(service))
+func (client Subscriber) ServiceCompleted(callback func(shell.ServiceRun)) (*Subscription, error) {
+	return Listen(client, shell.ServiceCompletedEvent, callback)
 }
 
-func (client Subscriber) RestartShell(callback func() error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.RestartShell, func(msg *nats.Msg) {
-		err := callback()
-		msg.Respond(errorOrOk(err))
+// Publish a lifecycle event on the subject named by event.Event
+func (client Publisher) ServiceEvent(event shell.ServiceEvent) error {
+	return Publish(client, api.Event[shell.ServiceEvent]{Subject: event.Event}, event)
+}
+
+func (client Subscriber) ServiceStarted(callback func(shell.ServiceEvent)) (*Subscription, error) {
+	return Listen(client, shell.ServiceStartedEvent, callback)
+}
+
+func (client Subscriber) ServiceExited(callback func(shell.ServiceEvent)) (*Subscription, error) {
+	return Listen(client, shell.ServiceExitedEvent, callback)
+}
+
+func (client Subscriber) ServiceRestarting(callback func(shell.ServiceEvent)) (*Subscription, error) {
+	return Listen(client, shell.ServiceRestartingEvent, callback)
+}
+
+func (client Subscriber) ServiceFailed(callback func(shell.ServiceEvent)) (*Subscription, error) {
+	return Listen(client, shell.ServiceFailedEvent, callback)
+}
+
+// Receive every lifecycle event of every service. Use the Event field to tell them apart.
+func (client Subscriber) ServiceEvents(callback func(shell.ServiceEvent)) (*Subscription, error) {
+	return Listen(client, shell.ServiceLifecycleEvents, callback)
+}
+
+// Ask a service to shut down. Use Instance to target the service.
+func (client Requester) Shutdown(ctx context.Context) error {
+	_, err := Request(ctx, client, shell.ShutdownEndpoint, api.Empty{})
+	return err
+}
+
+func (client Subscriber) RestartShell(callback func() error) (*Subscription, error) {
+	return Handle(client, shell.RestartShellEndpoint, func(api.Empty) (api.Empty, error) {
+		return api.Empty{}, callback()
 	})
 }
 
-func (client Publisher) RestartShell() {
-	client.nc.Publish(shell.RestartShell, []byte{})
+func (client Publisher) RestartShell() error {
+	return Notify(client, shell.RestartShellEndpoint, api.Empty{})
 }
 
-func (client Subscriber) QuitShell(callback func() error) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.QuitShell, func(msg *nats.Msg) {
-		err := callback()
-		msg.Respond(errorOrOk(err))
+func (client Subscriber) ReloadConfig(callback func() (shell.ConfigDiff, error)) (*Subscription, error) {
+	return Handle(client, shell.ReloadConfigEndpoint, func(api.Empty) (shell.ConfigDiff, error) {
+		return callback()
 	})
 }
 
-func (client Requester) QuitShell() error {
-	msg, _ := client.nc.Request(shell.QuitShell, nil, client.timeout)
-	return utils.DecodeAny[error](msg.Data)
+func (client Requester) ReloadConfig(ctx context.Context) (shell.ConfigDiff, error) {
+	return Request(ctx, client, shell.ReloadConfigEndpoint, api.Empty{})
+}
+
+func (client Subscriber) SetConfig(callback func(shell.SetConfigRequest) (shell.ConfigDiff, error)) (*Subscription, error) {
+	return Handle(client, shell.SetConfigEndpoint, callback)
+}
+
+func (client Requester) SetConfig(ctx context.Context, req shell.SetConfigRequest) (shell.ConfigDiff, error) {
+	return Request(ctx, client, shell.SetConfigEndpoint, req)
+}
+
+func (client Subscriber) AddService(callback func(shell.AddServiceRequest) error) (*Subscription, error) {
+	return Handle(client, shell.AddServiceEndpoint, noResult(callback))
 }
 
-func (client Subscriber) Config(callback func(string) any) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.GetService, func(msg *nats.Msg) {
-		key := utils.DecodeAny[string](msg.Data)
-		config := callback(key)
-		msg.Respond(utils.EncodeAny(config))
+func (client Requester) AddService(ctx context.Context, req shell.AddServiceRequest) error {
+	_, err := Request(ctx, client, shell.AddServiceEndpoint, req)
+	return err
+}
+
+func (client Subscriber) RemoveService(callback func(shell.RemoveServiceRequest) error) (*Subscription, error) {
+	return Handle(client, shell.RemoveServiceEndpoint, noResult(callback))
+}
+
+func (client Requester) RemoveService(ctx context.Context, req shell.RemoveServiceRequest) error {
+	_, err := Request(ctx, client, shell.RemoveServiceEndpoint, req)
+	return err
+}
+
+func (client Subscriber) QuitShell(callback func() error) (*Subscription, error) {
+	return Handle(client, shell.QuitShellEndpoint, func(api.Empty) (api.Empty, error) {
+		return api.Empty{}, callback()
 	})
 }
 
-func GetConfig[T any](client *Requester) T {
+func (client Requester) QuitShell(ctx context.Context) error {
+	_, err := Request(ctx, client, shell.QuitShellEndpoint, api.Empty{})
+	return err
+}
+
+func (client Subscriber) Config(callback func(string) (any, error)) (*Subscription, error) {
+	return Handle(client, shell.GetServiceEndpoint, callback)
+}
+
+// Get the config section of the service this process was started as
+func GetConfig[T any](ctx context.Context, client *Requester) (result T, err error) {
 	name := os.Getenv(shell.SERVICE_ENV_KEY)
 	if name == "" {
-		panic(fmt.Sprintf("Environment variable '%v' not set.", shell.SERVICE_ENV_KEY))
+		err = reply.Errorf(reply.NotConfigured, "Environment variable '%v' not set.", shell.SERVICE_ENV_KEY)
+		return
 	}
-	result, _ := GetServiceConfig[T](client, name)
-	return result
+	return GetServiceConfig[T](ctx, client, name)
 }
 
-func GetServiceConfig[T any](client *Requester, name string) (result T, err error) {
-	msg, err := client.nc.Request(shell.GetService, []byte(name), client.timeout)
-	if err != nil {
-		return
-	}
-	result = utils.DecodeAny[T](msg.Data)
-	return result, nil
+// The config section of a service is untyped in the shell, so it is decoded directly into T
+func GetServiceConfig[T any](ctx context.Context, client *Requester, name string) (T, error) {
+	return request[T](ctx, *client, shell.GetServiceEndpoint.Subject, name)
 }
 
-func (client Subscriber) ShellConfig(callback func() shell.Configuration) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.ShellConfig, func(msg *nats.Msg) {
-		config := callback()
-		msg.Respond(utils.EncodeAny(config))
-	})
+func (client Subscriber) ShellConfig(callback func() shell.Configuration) (*Subscription, error) {
+	return Handle(client, shell.ShellConfigEndpoint, noArguments(callback))
 }
 
-func (client Publisher) WH_SHELL(evt shell.ShellEventInfo) {
-	client.nc.Publish(shell.ShellEvent, utils.EncodeAny(evt))
+func (client Requester) ShellConfig(ctx context.Context) (shell.Configuration, error) {
+	return Request(ctx, client, shell.ShellConfigEndpoint, api.Empty{})
 }
 
-func (client Subscriber) WH_SHELL(callback func(shell.ShellEventInfo)) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.ShellEvent, func(msg *nats.Msg) {
-		evt := utils.DecodeAny[shell.ShellEventInfo](msg.Data)
-		callback(evt)
-	})
+func (client Publisher) WH_SHELL(evt shell.ShellEventInfo) error {
+	return Publish(client, shell.ShellEvents, evt)
 }
 
-func (client Publisher) WH_MOUSE(evt mouse.MouseEventInfo) {
-	client.nc.Publish(shell.MouseEvent, utils.EncodeAny(evt))
+func (client Subscriber) WH_SHELL(callback func(shell.ShellEventInfo)) (*Subscription, error) {
+	return Listen(client, shell.ShellEvents, callback)
 }
 
-func (client Subscriber) WH_MOUSE(callback func(mouse.MouseEventInfo) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.MouseEvent, func(msg *nats.Msg) {
-		evt := utils.DecodeAny[mouse.MouseEventInfo](msg.Data)
-		handled := callback(evt)
-		msg.Respond(utils.EncodeAny(handled))
-	})
+func (client Publisher) WH_MOUSE(evt mouse.MouseEventInfo) error {
+	return Notify(client, shell.MouseEventEndpoint, evt)
 }
-func (client Publisher) WH_KEYBOARD(evt keyboard.KeyboardEventInfo) {
-	client.nc.Publish(shell.KeyboardEvent, utils.EncodeAny(evt))
+
+func (client Subscriber) WH_MOUSE(callback func(mouse.MouseEventInfo) bool) (*Subscription, error) {
+	return HandleEach(client, shell.MouseEventEndpoint, infallible(callback))
 }
 
-func (client Subscriber) WH_KEYBOARD(callback func(keyboard.KeyboardEventInfo) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.KeyboardEvent, func(msg *nats.Msg) {
-		evt := utils.DecodeAny[keyboard.KeyboardEventInfo](msg.Data)
-		handled := callback(evt)
-		msg.Respond(utils.EncodeAny(handled))
-	})
+func (client Publisher) WH_KEYBOARD(evt keyboard.KeyboardEventInfo) error {
+	return Notify(client, shell.KeyboardEventEndpoint, evt)
 }
 
-func (client Subscriber) ToggleBackground(callback func() bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.ToggleBackground, func(msg *nats.Msg) {
-		msg.Respond(utils.EncodeAny(callback()))
-	})
+func (client Subscriber) WH_KEYBOARD(callback func(keyboard.KeyboardEventInfo) bool) (*Subscription, error) {
+	return HandleEach(client, shell.KeyboardEventEndpoint, infallible(callback))
 }
 
-func (client Requester) ToggleBackground() bool {
-	response, _ := client.nc.Request(shell.ToggleBackground, nil, client.timeout)
-	return utils.DecodeAny[bool](response.Data)
+func (client Requester) ToggleBackground(ctx context.Context) (bool, error) {
+	return Request(ctx, client, shell.ToggleBackgroundEndpoint, api.Empty{})
 }
 
-func (client Subscriber) ShellToast(callback func(shell.Toast)) (*nats.Subscription, error) {
-	return client.nc.Subscribe(shell.ShellToast, func(msg *nats.Msg) {
-		toast := utils.DecodeAny[shell.Toast](msg.Data)
-		callback(toast)
-	})
+func (client Subscriber) ToggleBackground(callback func() bool) (*Subscription, error) {
+	return Handle(client, shell.ToggleBackgroundEndpoint, noArguments(callback))
+}
+
+func (client Publisher) ServiceReady(service string) error {
+	return Publish(client, shell.ServiceReadyEvent, service)
+}
+
+func (client Subscriber) ServiceReady(callback func(string)) (*Subscription, error) {
+	return Listen(client, shell.ServiceReadyEvent, callback)
+}
+
+func (client Requester) ListServices(ctx context.Context) ([]shell.ServiceStatusInfo, error) {
+	return Request(ctx, client, shell.ListServicesEndpoint, api.Empty{})
+}
+
+func (client Subscriber) ListServices(callback func() []shell.ServiceStatusInfo) (*Subscription, error) {
+	return Handle(client, shell.ListServicesEndpoint, noArguments(callback))
+}
+
+func (client Requester) ServiceStatus(ctx context.Context, service string) (shell.ServiceStatusInfo, error) {
+	return Request(ctx, client, shell.ServiceStatusEndpoint, service)
+}
+
+func (client Subscriber) ServiceStatus(callback func(string) (shell.ServiceStatusInfo, error)) (*Subscription, error) {
+	return Handle(client, shell.ServiceStatusEndpoint, callback)
+}
+
+func (client Requester) Trace(ctx context.Context, id string) ([]trace.Span, error) {
+	return Request(ctx, client, shell.TraceEndpoint, id)
+}
+
+func (client Subscriber) Trace(callback func(string) []trace.Span) (*Subscription, error) {
+	return Handle(client, shell.TraceEndpoint, infallible(callback))
+}
+
+func (client Subscriber) TraceSpans(callback func(trace.Span)) (*Subscription, error) {
+	return Listen(client, shell.TraceSpans, callback)
+}
+
+func (client Publisher) ShellToast(toast shell.Toast) error {
+	return Publish(client, shell.ShellToastEvent, toast)
 }
 
-func (client Publisher) ShellToast(toast shell.Toast) {
-	client.nc.Publish(shell.ShellToast, utils.EncodeAny(toast))
+func (client Subscriber) ShellToast(callback func(shell.Toast)) (*Subscription, error) {
+	return Listen(client, shell.ShellToastEvent, callback)
 }
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/shell"
+)
+
+func TestServiceEvents(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	r := connect(t, s)
+
+	exited := make(chan shell.ServiceEvent, 10)
+	all := make(chan shell.ServiceEvent, 10)
+	r.Subscribe.ServiceExited(func(e shell.ServiceEvent) { exited <- e })
+	r.Subscribe.ServiceEvents(func(e shell.ServiceEvent) { all <- e })
+	r.nc.Flush()
+
+	c.Publish.ServiceEvent(shell.ServiceEvent{Event: shell.ServiceStarted, Name: "driver", Pid: 42})
+	c.Publish.ServiceEvent(shell.ServiceEvent{Event: shell.ServiceExited, Name: "driver", Pid: 42, ExitCode: 3})
+
+	receive := func(events chan shell.ServiceEvent) shell.ServiceEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatalf("Expected an event")
+		}
+		return shell.ServiceEvent{}
+	}
+	if e := receive(exited); e.Event != shell.ServiceExited || e.ExitCode != 3 {
+		t.Fatalf("Expected the exited event, got %+v", e)
+	}
+	if e := receive(all); e.Event != shell.ServiceStarted || e.Pid != 42 {
+		t.Fatalf("Expected the started event, got %+v", e)
+	}
+	if e := receive(all); e.Event != shell.ServiceExited {
+		t.Fatalf("Expected the exited event, got %+v", e)
+	}
+}
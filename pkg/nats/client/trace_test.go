@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
+	"github.com/operdies/windows-nats-shell/pkg/nats/trace"
+)
+
+func TestTracing(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	c.Subscribe.record = true
+	c.Subscribe.service = "test"
+	c.Request.trace = true
+
+	spans := make(chan trace.Span, 10)
+	c.Subscribe.TraceSpans(func(s trace.Span) { spans <- s })
+
+	quadruple := api.Endpoint[int, int]{Subject: "Test.Quadruple"}
+	Handle(*c.Subscribe, double, func(i int) (int, error) { return i * 2, nil })
+	HandleContext(*c.Subscribe, quadruple, func(ctx context.Context, i int) (int, error) {
+		i, err := Request(ctx, *c.Request, double, i)
+		if err != nil {
+			return 0, err
+		}
+		return Request(ctx, *c.Request, double, i)
+	})
+	c.nc.Flush()
+
+	if result, err := Request(context.Background(), *c.Request, quadruple, 1); err != nil || result != 4 {
+		t.Fatalf("Expected 4, got %v (%v)", result, err)
+	}
+
+	var recorded []trace.Span
+	for len(recorded) < 3 {
+		select {
+		case s := <-spans:
+			recorded = append(recorded, s)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 3 spans, got %+v", recorded)
+		}
+	}
+	roots := trace.Tree(recorded)
+	if len(roots) != 1 || roots[0].Subject != quadruple.Subject || len(roots[0].Children) != 2 {
+		t.Fatalf("Expected %s to be the root of 2 spans, got %+v", quadruple.Subject, roots)
+	}
+	for _, child := range roots[0].Children {
+		if child.Subject != double.Subject || child.Trace != roots[0].Trace || child.Service != "test" {
+			t.Fatalf("Expected %s in the same trace, got %+v", double.Subject, child)
+		}
+	}
+}
+
+func TestNoTraceHeadersWithoutTracing(t *testing.T) {
+	s := runServer(t)
+	c := connect(t, s)
+	sub, _ := c.nc.SubscribeSync(event.Subject)
+	c.nc.Flush()
+
+	Publish(*c.Publish, event, "untraced")
+	msg, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get(trace.TraceHeader) != "" || msg.Header.Get(trace.ParentHeader) != "" {
+		t.Fatalf("Expected no trace headers, got %v", msg.Header)
+	}
+}
@@ -1,187 +1,128 @@
 package client
 
 import (
-	"time"
-
-	"github.com/nats-io/nats.go"
+	"context"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api"
 	"github.com/operdies/windows-nats-shell/pkg/nats/api/windows"
-	"github.com/operdies/windows-nats-shell/pkg/utils"
 	"github.com/operdies/windows-nats-shell/pkg/wintypes"
 )
 
-func (client Requester) Windows() []wintypes.Window {
-	response, _ := client.nc.Request(windows.GetWindows, nil, client.timeout)
-	return utils.DecodeAny[[]wintypes.Window](response.Data)
+func (client Requester) Windows(ctx context.Context) ([]wintypes.Window, error) {
+	return Request(ctx, client, windows.GetWindowsEndpoint, api.Empty{})
+}
+
+func (client Subscriber) GetWindows(callback func() []wintypes.Window) (*Subscription, error) {
+	return Handle(client, windows.GetWindowsEndpoint, noArguments(callback))
+}
+
+func (client Publisher) WindowsUpdated(w []wintypes.Window) error {
+	return Publish(client, windows.WindowsUpdatedEvent, w)
+}
+
+func (client Subscriber) WindowsUpdated(callback func([]wintypes.Window)) (*Subscription, error) {
+	return Listen(client, windows.WindowsUpdatedEvent, callback)
+}
+
+func (client Requester) IsWindowFocused(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.IsWindowFocusedEndpoint, hwnd)
+}
+
+func (client Subscriber) IsWindowFocused(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.IsWindowFocusedEndpoint, infallible(callback))
+}
+
+func (client Requester) SetFocus(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.FocusWindowEndpoint, hwnd)
+}
+
+func (client Subscriber) SetFocus(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.FocusWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) MoveWindow(ctx context.Context, me windows.MoveEvent) (bool, error) {
+	return Request(ctx, client, windows.MoveWindowEndpoint, me)
+}
+
+func (client Subscriber) MoveWindow(callback func(windows.MoveEvent) bool) (*Subscription, error) {
+	return Handle(client, windows.MoveWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) ResizeWindow(ctx context.Context, re windows.ResizeEvent) (bool, error) {
+	return Request(ctx, client, windows.ResizeWindowEndpoint, re)
+}
+
+func (client Subscriber) ResizeWindow(callback func(windows.ResizeEvent) bool) (*Subscription, error) {
+	return Handle(client, windows.ResizeWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) MinimizeWindow(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.MinimizeWindowEndpoint, hwnd)
+}
+
+func (client Subscriber) MinimizeWindow(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.MinimizeWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) RestoreWindow(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.RestoreWindowEndpoint, hwnd)
+}
+
+func (client Subscriber) RestoreWindow(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.RestoreWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) MaximizeWindow(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.MaximizeWindowEndpoint, hwnd)
+}
+
+func (client Subscriber) MaximizeWindow(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.MaximizeWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) RestoreOrMinimizeWindow(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.RestoreOrMinimizeWindowEndpoint, hwnd)
+}
+
+func (client Subscriber) RestoreOrMinimizeWindow(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.RestoreOrMinimizeWindowEndpoint, infallible(callback))
+}
+
+func (client Requester) FocusPrevious(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.FocusPreviousEndpoint, hwnd)
 }
 
-func (client Subscriber) WindowsUpdated(callback func([]wintypes.Window)) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.WindowsUpdated, func(m *nats.Msg) {
-		windows := utils.DecodeAny[[]wintypes.Window](m.Data)
-		callback(windows)
-	})
+func (client Subscriber) FocusPrevious(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.FocusPreviousEndpoint, infallible(callback))
 }
 
-func (client Requester) SetFocus(window uint64) bool {
-	msg, _ := client.nc.Request(windows.FocusWindow, utils.EncodeAny(window), time.Second)
-	return utils.DecodeAny[bool](msg.Data)
+func (client Requester) FocusNext(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.FocusNextEndpoint, hwnd)
 }
 
-func (client Subscriber) GetWindows(callback func() []wintypes.Window) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.GetWindows, func(m *nats.Msg) {
-		windows := callback()
-		m.Respond(utils.EncodeAny(windows))
-	})
+func (client Subscriber) FocusNext(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.FocusNextEndpoint, infallible(callback))
 }
 
-func (client Subscriber) IsWindowFocused(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.IsWindowFocused, func(m *nats.Msg) {
-		window := utils.DecodeAny[wintypes.HWND](m.Data)
-		result := callback(window)
-		m.Respond(utils.EncodeAny(result))
-	})
+func (client Requester) HideBorder(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.HideBorderEndpoint, hwnd)
 }
 
-func (client Subscriber) SetFocus(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.FocusWindow, func(m *nats.Msg) {
-		window := utils.DecodeAny[wintypes.HWND](m.Data)
-		result := callback(window)
-		m.Respond(utils.EncodeAny(result))
-	})
+func (client Subscriber) HideBorder(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.HideBorderEndpoint, infallible(callback))
 }
 
-func (client Publisher) WindowsUpdated(w []wintypes.Window) {
-	client.nc.Publish(windows.WindowsUpdated, utils.EncodeAny(w))
+func (client Requester) ShowBorder(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.ShowBorderEndpoint, hwnd)
 }
 
-func (client Subscriber) HideBorder(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.HideBorder, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
+func (client Subscriber) ShowBorder(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.ShowBorderEndpoint, infallible(callback))
 }
 
-func (client Requester) HideBorder(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.HideBorder, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
+func (client Requester) ToggleBorder(ctx context.Context, hwnd wintypes.HWND) (bool, error) {
+	return Request(ctx, client, windows.ToggleBorderEndpoint, hwnd)
 }
 
-func (client Subscriber) ShowBorder(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.ShowBorder, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
+func (client Subscriber) ToggleBorder(callback func(wintypes.HWND) bool) (*Subscription, error) {
+	return Handle(client, windows.ToggleBorderEndpoint, infallible(callback))
 }
-
-func (client Requester) ShowBorder(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.ShowBorder, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
-}
-
-func (client Subscriber) ToggleBorder(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.ToggleBorder, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
-}
-
-func (client Requester) ToggleBorder(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.ToggleBorder, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
-}
-
-// func (client Subscriber) MoveWindow(callback func(windows.MoveEvent) bool) (*nats.Subscription, error) {
-// 	return client.nc.Subscribe(windows.MoveWindow, func(msg *nats.Msg) {
-// 		result := callback(utils.DecodeAny[windows.MoveEvent](msg.Data))
-// 		msg.Respond(utils.EncodeAny(result))
-// 	})
-// }
-//
-// func (client Requester) MoveWindow(me windows.MoveEvent) bool {
-// 	msg, _ := client.nc.Request(windows.MoveWindow, utils.EncodeAny(me), client.timeout)
-// 	return utils.DecodeAny[bool](msg.Data)
-// }
-//
-// func (client Subscriber) ResizeWindow(callback func(windows.ResizeEvent) bool) (*nats.Subscription, error) {
-// 	return client.nc.Subscribe(windows.ResizeWindow, func(msg *nats.Msg) {
-// 		result := callback(utils.DecodeAny[windows.ResizeEvent](msg.Data))
-// 		msg.Respond(utils.EncodeAny(result))
-// 	})
-// }
-//
-// func (client Requester) ResizeWindow(resizeEvent windows.ResizeEvent) bool {
-// 	msg, _ := client.nc.Request(windows.ResizeWindow, utils.EncodeAny(resizeEvent), client.timeout)
-// 	return utils.DecodeAny[bool](msg.Data)
-// }
-
-func (client Subscriber) MinimizeWindow(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.MinimizeWindow, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
-}
-
-func (client Requester) MinimizeWindow(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.MinimizeWindow, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
-}
-
-func (client Subscriber) RestoreWindow(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.RestoreWindow, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
-}
-
-func (client Requester) RestoreWindow(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.RestoreWindow, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
-}
-
-func (client Subscriber) RestoreOrMinimizeWindow(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-	return client.nc.Subscribe(windows.RestoreOrMinimizeWindow, func(msg *nats.Msg) {
-		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-		msg.Respond(utils.EncodeAny(result))
-	})
-}
-
-func (client Requester) RestoreOrMinimizeWindow(hwnd wintypes.HWND) bool {
-	msg, _ := client.nc.Request(windows.RestoreOrMinimizeWindow, utils.EncodeAny(hwnd), client.timeout)
-	return utils.DecodeAny[bool](msg.Data)
-}
-
-// func (client Subscriber) MaximizeWindow(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-// 	return client.nc.Subscribe(windows.MaximizeWindow, func(msg *nats.Msg) {
-// 		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-// 		msg.Respond(utils.EncodeAny(result))
-// 	})
-// }
-//
-// func (client Requester) MaximizeWindow(hwnd wintypes.HWND) bool {
-// 	msg, _ := client.nc.Request(windows.MaximizeWindow, utils.EncodeAny(hwnd), client.timeout)
-// 	return utils.DecodeAny[bool](msg.Data)
-// }
-//
-// func (client Subscriber) FocusPrevious(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-// 	return client.nc.Subscribe(windows.FocusPrevious, func(msg *nats.Msg) {
-// 		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-// 		msg.Respond(utils.EncodeAny(result))
-// 	})
-// }
-//
-// func (client Requester) FocusPrevious(hwnd wintypes.HWND) bool {
-// 	msg, _ := client.nc.Request(windows.FocusPrevious, utils.EncodeAny(hwnd), client.timeout)
-// 	return utils.DecodeAny[bool](msg.Data)
-// }
-//
-// func (client Subscriber) FocusNext(callback func(wintypes.HWND) bool) (*nats.Subscription, error) {
-// 	return client.nc.Subscribe(windows.FocusNext, func(msg *nats.Msg) {
-// 		result := callback(utils.DecodeAny[wintypes.HWND](msg.Data))
-// 		msg.Respond(utils.EncodeAny(result))
-// 	})
-// }
-//
-// func (client Requester) FocusNext(hwnd wintypes.HWND) bool {
-// 	msg, _ := client.nc.Request(windows.FocusNext, utils.EncodeAny(hwnd), client.timeout)
-// 	return utils.DecodeAny[bool](msg.Data)
-// }
-//
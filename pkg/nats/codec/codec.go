@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The NATS header used to announce the encoding of a payload
+	Header = "Content-Type"
+)
+
+// Codec translates between values and message payloads
+type Codec interface {
+	// The value of the Content-Type header of payloads produced by this codec
+	ContentType() string
+	Encode(value any) ([]byte, error)
+	Decode(data []byte, value any) error
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string                 { return "application/yaml" }
+func (yamlCodec) Encode(value any) ([]byte, error)    { return yaml.Marshal(value) }
+func (yamlCodec) Decode(data []byte, value any) error { return yaml.Unmarshal(data, value) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                 { return "application/json" }
+func (jsonCodec) Encode(value any) ([]byte, error)    { return json.Marshal(value) }
+func (jsonCodec) Decode(data []byte, value any) error { return json.Unmarshal(data, value) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string                 { return "application/msgpack" }
+func (msgpackCodec) Encode(value any) ([]byte, error)    { return msgpack.Marshal(value) }
+func (msgpackCodec) Decode(data []byte, value any) error { return msgpack.Unmarshal(data, value) }
+
+var (
+	YAML    Codec = yamlCodec{}
+	JSON    Codec = jsonCodec{}
+	MsgPack Codec = msgpackCodec{}
+	// The codec used when a message does not specify one.
+	// YAML is the default so plain `nats pub` payloads keep working.
+	Default = YAML
+)
+
+var (
+	registry = map[string]Codec{
+		YAML.ContentType():    YAML,
+		JSON.ContentType():    JSON,
+		MsgPack.ContentType(): MsgPack,
+		// Common aliases
+		"application/x-yaml":    YAML,
+		"text/yaml":             YAML,
+		"application/x-msgpack": MsgPack,
+		"yaml":                  YAML,
+		"json":                  JSON,
+		"msgpack":               MsgPack,
+	}
+	registryLock sync.RWMutex
+)
+
+// Make a codec available for messages with the given content type
+func Register(contentType string, c Codec) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[normalize(contentType)] = c
+}
+
+func normalize(contentType string) string {
+	// Ignore parameters such as '; charset=utf-8'
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// Get the codec for a content type. An empty content type selects the Default codec.
+func ForContentType(contentType string) (Codec, error) {
+	contentType = normalize(contentType)
+	if contentType == "" {
+		return Default, nil
+	}
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	if c, ok := registry[contentType]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("Unsupported content type '%s'.", contentType)
+}
+
+// Decode a payload into a new value of type T. An empty payload decodes to the zero value.
+func Decode[T any](c Codec, data []byte) (result T, err error) {
+	if len(data) == 0 {
+		return
+	}
+	err = c.Decode(data, &result)
+	return
+}
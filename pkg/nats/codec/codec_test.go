@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/screen"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []Codec{YAML, JSON, MsgPack} {
+		sent := reply.Success(screen.Resolution{Width: 2560, Height: 1440})
+		data, err := c.Encode(sent)
+		if err != nil {
+			t.Fatalf("%s: %v", c.ContentType(), err)
+		}
+		received, err := Decode[reply.Envelope[screen.Resolution]](c, data)
+		if err != nil {
+			t.Fatalf("%s: %v", c.ContentType(), err)
+		}
+		if received != sent {
+			t.Fatalf("%s: Expected %+v, got %+v", c.ContentType(), sent, received)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, c := range []Codec{YAML, JSON, MsgPack} {
+		_, err := Decode[screen.Resolution](c, []byte("{{{ not a resolution"))
+		if err == nil {
+			t.Fatalf("%s: Expected a decode error", c.ContentType())
+		}
+	}
+
+	// An empty payload is the zero value
+	r, err := Decode[screen.Resolution](JSON, nil)
+	if err != nil || r != (screen.Resolution{}) {
+		t.Fatalf("Expected zero value, got %+v (%v)", r, err)
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	cases := map[string]Codec{
+		"":                                YAML,
+		"application/json":                JSON,
+		"Application/JSON; charset=utf-8": JSON,
+		"application/msgpack":             MsgPack,
+		"yaml":                            YAML,
+	}
+	for ct, expected := range cases {
+		c, err := ForContentType(ct)
+		if err != nil {
+			t.Fatalf("'%s': %v", ct, err)
+		}
+		if c != expected {
+			t.Fatalf("'%s': Expected %s, got %s", ct, expected.ContentType(), c.ContentType())
+		}
+	}
+
+	if _, err := ForContentType("text/html"); err == nil {
+		t.Fatalf("Expected an error for an unsupported content type")
+	}
+}
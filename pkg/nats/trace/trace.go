@@ -0,0 +1,168 @@
+// Package trace follows an interaction as it travels between services.
+//
+// Every message sent by a client carries the id of the trace it belongs to, and the id of the
+// span it was sent from. Subscribers start a new span for every message they handle, so the
+// messages sent while handling it become its children. Recorded spans are collected by the
+// shell, which can reconstruct the span tree of a trace.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/operdies/windows-nats-shell/pkg/nats/api/reply"
+)
+
+const (
+	// The trace a message belongs to
+	TraceHeader = "Trace-Id"
+	// The span the message was sent from
+	ParentHeader = "Parent-Span-Id"
+)
+
+// A span is a message handled by a service
+type Span struct {
+	Trace   string
+	Id      string
+	Parent  string
+	Subject string
+	Service string
+	Start   time.Time
+	// The time it took to handle the message
+	Duration time.Duration
+	// The outcome of handling the message
+	Code    reply.ErrorCode
+	Message string
+}
+
+// The position of a message in a trace
+type SpanContext struct {
+	Trace string
+	Span  string
+}
+
+type key struct{}
+
+// Create a random identifier
+func NewId() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Get a context in which outgoing messages are children of a span
+func WithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, key{}, sc)
+}
+
+// Get the span outgoing messages are sent from
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(key{}).(SpanContext)
+	return sc, ok
+}
+
+// Add the trace headers to an outgoing message. Messages sent outside of a span start a new trace.
+func Inject(ctx context.Context, h nats.Header) {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		h.Set(TraceHeader, NewId())
+		return
+	}
+	h.Set(TraceHeader, sc.Trace)
+	h.Set(ParentHeader, sc.Span)
+}
+
+// Start a span for an incoming message. Messages without trace headers start a new trace.
+func Extract(h nats.Header) (sc SpanContext, parent string) {
+	sc.Trace = h.Get(TraceHeader)
+	if sc.Trace == "" {
+		sc.Trace = NewId()
+	}
+	sc.Span = NewId()
+	return sc, h.Get(ParentHeader)
+}
+
+// A span and the spans started while handling it
+type Node struct {
+	Span
+	Children []*Node
+}
+
+// Reconstruct the span trees of a trace. Spans whose parent was not recorded are roots.
+// Siblings are ordered by their start time.
+func Tree(spans []Span) []*Node {
+	sorted := append([]Span{}, spans...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	nodes := map[string]*Node{}
+	for _, s := range sorted {
+		nodes[s.Id] = &Node{Span: s}
+	}
+	var roots []*Node
+	for _, s := range sorted {
+		node := nodes[s.Id]
+		if parent, ok := nodes[s.Parent]; ok && s.Parent != s.Id {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// Store keeps the most recently recorded spans
+type Store struct {
+	lock  sync.Mutex
+	spans []Span
+	next  int
+	full  bool
+}
+
+// Create a store which keeps the last capacity spans
+func NewStore(capacity int) *Store {
+	return &Store{spans: make([]Span, capacity)}
+}
+
+func (s *Store) Add(span Span) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.spans) == 0 {
+		return
+	}
+	s.spans[s.next] = span
+	s.next = (s.next + 1) % len(s.spans)
+	s.full = s.full || s.next == 0
+}
+
+// Get the stored spans, oldest first
+func (s *Store) all() []Span {
+	if s.full {
+		return append(append([]Span{}, s.spans[s.next:]...), s.spans[:s.next]...)
+	}
+	return append([]Span{}, s.spans[:s.next]...)
+}
+
+// Get the stored spans of a trace. If trace is empty, the spans of the most recent trace are returned.
+func (s *Store) Get(trace string) []Span {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	all := s.all()
+	if trace == "" && len(all) > 0 {
+		trace = all[len(all)-1].Trace
+	}
+	var result []Span
+	for _, span := range all {
+		if span.Trace == trace {
+			result = append(result, span)
+		}
+	}
+	return result
+}
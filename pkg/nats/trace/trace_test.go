@@ -0,0 +1,59 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestPropagation(t *testing.T) {
+	h := nats.Header{}
+	Inject(context.Background(), h)
+	root, parent := Extract(h)
+	if root.Trace == "" || root.Trace != h.Get(TraceHeader) || parent != "" {
+		t.Fatalf("Expected a new trace without a parent, got %+v (%s)", root, parent)
+	}
+
+	h = nats.Header{}
+	Inject(WithSpan(context.Background(), root), h)
+	child, parent := Extract(h)
+	if child.Trace != root.Trace || parent != root.Span || child.Span == root.Span {
+		t.Fatalf("Expected a child of %+v, got %+v (%s)", root, child, parent)
+	}
+}
+
+func TestTree(t *testing.T) {
+	now := time.Now()
+	spans := []Span{
+		{Trace: "t", Id: "c2", Parent: "r", Start: now.Add(2)},
+		{Trace: "t", Id: "g", Parent: "c1", Start: now.Add(3)},
+		{Trace: "t", Id: "c1", Parent: "r", Start: now.Add(1)},
+		{Trace: "t", Id: "r", Start: now},
+	}
+	roots := Tree(spans)
+	if len(roots) != 1 || roots[0].Id != "r" {
+		t.Fatalf("Expected a single root, got %+v", roots)
+	}
+	children := roots[0].Children
+	if len(children) != 2 || children[0].Id != "c1" || children[1].Id != "c2" {
+		t.Fatalf("Expected children ordered by start time, got %+v", children)
+	}
+	if len(children[0].Children) != 1 || children[0].Children[0].Id != "g" {
+		t.Fatalf("Expected a grandchild, got %+v", children[0].Children)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		s.Add(Span{Trace: id, Id: id})
+	}
+	if spans := s.Get("a"); len(spans) != 0 {
+		t.Fatalf("Expected the oldest span to be evicted, got %+v", spans)
+	}
+	if spans := s.Get(""); len(spans) != 1 || spans[0].Id != "d" {
+		t.Fatalf("Expected the most recent trace, got %+v", spans)
+	}
+}
@@ -0,0 +1,163 @@
+// Package cron parses cron expressions, and finds the times they match.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A set of values of a field, one bit per value
+type field uint64
+
+func (f field) has(v int) bool {
+	return f&(1<<v) != 0
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow field
+	// Set if either day field is not '*'. If both are set, a day matches if either matches.
+	domRestricted, dowRestricted bool
+	// Set for '@every' schedules, which do not use the fields
+	every time.Duration
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse a standard cron expression with five fields: minute, hour, day of month, month and day of week.
+// Fields support '*', lists, ranges and steps, e.g. '*/15 9-17 * * 1-5'. Sunday is both 0 and 7.
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are supported,
+// as well as '@every <duration>', e.g. '@every 90m'.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid schedule '%s'. %v", expr, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("Invalid schedule '%s'. The interval must be positive.", expr)
+		}
+		return &Schedule{every: d}, nil
+	}
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid schedule '%s'. Expected 5 fields, got %d.", expr, len(fields))
+	}
+	bounds := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day of month", 1, 31},
+		{"month", 1, 12},
+		{"day of week", 0, 7},
+	}
+	var parsed [5]field
+	for i, f := range fields {
+		var err error
+		if parsed[i], err = parseField(f, bounds[i].min, bounds[i].max); err != nil {
+			return nil, fmt.Errorf("Invalid %s in schedule '%s'. %v", bounds[i].name, expr, err)
+		}
+	}
+	s := &Schedule{
+		minute:        parsed[0],
+		hour:          parsed[1],
+		dom:           parsed[2],
+		month:         parsed[3],
+		dow:           parsed[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}
+	// Sunday is both 0 and 7
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(f string, min, max int) (field, error) {
+	var result field
+	for _, part := range strings.Split(f, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("'%s' is not a valid step.", stepText)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loText); err != nil {
+				return 0, fmt.Errorf("'%s' is not a number.", loText)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiText); err != nil {
+					return 0, fmt.Errorf("'%s' is not a number.", hiText)
+				}
+			} else if hasStep {
+				// 'n/step' means from n to the end
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("'%s' is not within %d-%d.", rng, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			result |= 1 << v
+		}
+	}
+	return result, nil
+}
+
+func (s *Schedule) day(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Get the first time after t which matches the schedule. Returns the zero time if nothing
+// matches within five years, e.g. for the 31st of February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
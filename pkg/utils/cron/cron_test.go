@@ -0,0 +1,49 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	start := time.Date(2023, time.March, 15, 10, 7, 30, 0, time.UTC)
+	cases := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2023, time.March, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, time.March, 15, 10, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2023, time.March, 15, 11, 5, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2023, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2023, time.March, 15, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * 5", time.Date(2023, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)},
+		{"1,2,58 10 * * *", time.Date(2023, time.March, 15, 10, 58, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", start.Add(90 * time.Minute)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if next := s.Next(start); !next.Equal(c.expected) {
+			t.Fatalf("%s: expected %v, got %v", c.expr, c.expected, next)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every", "@every -1m", "@often"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Expected '%s' to be invalid", expr)
+		}
+	}
+}
@@ -6,12 +6,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-	"unsafe"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/operdies/windows-nats-shell/pkg/utils/query"
-	"github.com/operdies/windows-nats-shell/pkg/winapi"
-	"github.com/operdies/windows-nats-shell/pkg/wintypes"
 )
 
 type WatchedDir struct {
@@ -71,22 +68,6 @@ func includeFile(name string) bool {
 	return query.Contains(_extensions, ext)
 }
 
-func calcExtHasAssoc(ext string) bool {
-	bytes := []byte(ext)
-	ptr := unsafe.Pointer(&bytes[0])
-	var size wintypes.DWORD = 200
-	sizePtr := unsafe.Pointer(&size)
-	res := make([]byte, size)
-	resultPtr := unsafe.Pointer(&res[0])
-	hResult := winapi.AssocQueryString(wintypes.ASSOCF_NONE, wintypes.ASSOCSTR_FRIENDLYDOCNAME, wintypes.LPCSTR(ptr), 0, wintypes.LPSTR(resultPtr), uintptr(sizePtr))
-	v := wintypes.SUCCEEDED(hResult)
-	// resStr := res[:size]
-	if v {
-		// fmt.Printf("Extension %s supported: %v (%v) (%v)\n", ext, v, string(resStr), hResult)
-	}
-	return v
-}
-
 func pathEqual(s1, s2 string) bool {
 	normalize := func(s string) string {
 		s = strings.ToLower(strings.ReplaceAll(s, "\\", "/"))
@@ -105,6 +86,10 @@ func (w *WatchedDir) indexFiles() {
 		defer w.indexLock.Unlock()
 		items := map[string]string{}
 		filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+			// d is nil if the root could not be read
+			if err != nil {
+				return nil
+			}
 			if d.IsDir() {
 				if w.recursive {
 					return nil
@@ -136,6 +121,9 @@ func Create(root string, recursive, watch bool) *WatchedDir {
 	if watch {
 		if recursive {
 			filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
 				if d.IsDir() {
 					err := watcher.Add(path)
 					if err != nil {
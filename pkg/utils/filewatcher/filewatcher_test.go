@@ -4,6 +4,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestFileIndexing(t *testing.T) {
@@ -35,6 +36,41 @@ func TestFileUpdates(t *testing.T) {
 	t.Log(w.Files())
 }
 
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "config.yml")
+	os.WriteFile(file, []byte("a: 1"), 0644)
+	os.WriteFile(path.Join(dir, "other.yml"), []byte("b: 1"), 0644)
+
+	changed := make(chan bool, 10)
+	w, err := WatchFile(file, func() { changed <- true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	os.WriteFile(path.Join(dir, "other.yml"), []byte("b: 2"), 0644)
+	select {
+	case <-changed:
+		t.Fatalf("Expected changes to other files to be ignored")
+	case <-time.After(settleTime * 2):
+	}
+
+	// Several writes in quick succession are reported once
+	os.WriteFile(file, []byte("a: 2"), 0644)
+	os.WriteFile(file, []byte("a: 3"), 0644)
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a change")
+	}
+	select {
+	case <-changed:
+		t.Fatalf("Expected a single change")
+	case <-time.After(settleTime * 2):
+	}
+}
+
 // Recursive watching not implemented
 // func TestRecursiveFileUpdates(t *testing.T) {
 // }
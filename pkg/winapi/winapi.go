@@ -5,7 +5,9 @@ package winapi
 
 import (
 	"log"
+	"strings"
 	"sync"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -126,13 +128,19 @@ func AssocQueryString(flags wintypes.AssocF, str wintypes.AssocStr, pszAssoc, ps
 	return wintypes.HRESULT(r)
 }
 
+// Get a pointer to a null terminated copy of s
 func strPtr(s string) wintypes.LPCSTR {
-	v := []byte(s)
+	v := append([]byte(s), 0)
 	vv := unsafe.Pointer(&v[0])
 	return wintypes.LPCSTR(vv)
 }
 
 func StartDetachedProcess(proc string, admin bool) error {
+	return StartDetachedProcessIn(proc, nil, "", admin)
+}
+
+// Start a process with arguments in a working directory. An empty directory is the current directory.
+func StartDetachedProcessIn(proc string, args []string, dir string, admin bool) error {
 	const sw_shownormal = 1
 	procPtr := strPtr(proc)
 	var verbPtr wintypes.LPCSTR = 0
@@ -141,7 +149,18 @@ func StartDetachedProcess(proc string, admin bool) error {
 		verb = `runas`
 		verbPtr = strPtr(verb)
 	}
-	_, err := ShellExecute(0, verbPtr, procPtr, 0, 0, sw_shownormal)
+	var argsPtr, dirPtr wintypes.LPCSTR = 0, 0
+	if len(args) > 0 {
+		escaped := make([]string, len(args))
+		for i, arg := range args {
+			escaped[i] = syscall.EscapeArg(arg)
+		}
+		argsPtr = strPtr(strings.Join(escaped, " "))
+	}
+	if dir != "" {
+		dirPtr = strPtr(dir)
+	}
+	_, err := ShellExecute(0, verbPtr, procPtr, argsPtr, dirPtr, sw_shownormal)
 	return err
 }
 
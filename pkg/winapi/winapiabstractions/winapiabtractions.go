@@ -233,6 +233,13 @@ func RestoreStyles(hwnd wintypes.HWND) bool {
 	return true
 }
 
+// Restore the styles of every window whose border was hidden by this process
+func RestoreBorders() {
+	for hwnd := range windowStyles {
+		RestoreStyles(hwnd)
+	}
+}
+
 func ToggleBorder(hwnd wintypes.HWND) bool {
 	if BordersEnabled(hwnd) {
 		HideBorder(hwnd)
@@ -2,8 +2,6 @@ package wintypes
 
 import (
 	"unsafe"
-
-	"github.com/operdies/windows-nats-shell/pkg/nats/api/windows"
 )
 
 // SystemParametersInfo(SPI_SETFOREGROUNDLOCKTIMEOUT, 0, 0, SPIF_+UPDATEINIFILE)
@@ -205,13 +203,17 @@ const (
 	ASSOCSTR_MAX
 )
 
+type POINT struct {
+	X, Y LONG
+}
+
 type MSG struct {
 	Hwnd     HWND
 	Message  uint32
 	WParam   WPARAM
 	LParam   LPARAM
 	Time     DWORD
-	Pt       windows.Point
+	Pt       POINT
 	LPrivate DWORD
 }
 